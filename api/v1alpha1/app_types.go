@@ -18,8 +18,13 @@ package v1alpha1
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -43,30 +48,66 @@ type AppSpec struct {
 	ImageDigest string `json:"imageDigest,omitempty"`
 
 	//+kubebuilder:validation:Optional
-	// Image Pull Secrets
-	ImagePullSecrets string `json:"imagePullSecrets,omitempty"`
+	// Image Pull Secrets, added to the pod and the app's ServiceAccount
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
 
-	// Instances/Replicas
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=Deployment;StatefulSet;Job;CronJob
+	// +kubebuilder:default:="Deployment"
+	// Kind of workload running the App, defaults to Deployment. StatefulSets give each instance a
+	// stable identity through a headless Service and its own claim for every claim volume.
+	// Jobs and CronJobs run batch work to completion and get no Service or routes.
+	WorkloadKind string `json:"workloadKind,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Settings for the Job and CronJob workload kinds
+	Job *Job `json:"job,omitempty"`
+
+	// Instances/Replicas, ignored while autoscaling is enabled
 	//+kubebuilder:validation:Optional
 	// +kubebuilder:default:=1
 	Instances *int32 `json:"instances,omitempty"`
 
-	// Memory Request/Limit, defaults to 256Mi
 	//+kubebuilder:validation:Optional
-	// +kubebuilder:default:="256Mi"
+	// Horizontal autoscaling, replaces Instances when set
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Canary release of a new version, shifting traffic to it in steps
+	Canary *Canary `json:"canary,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Blue/green releases, bringing up each new version alongside the live one until it is promoted
+	BlueGreen *BlueGreen `json:"blueGreen,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Pod Disruption Budget override, defaults to allowing one unavailable instance.
+	// No budget is created for single instance Apps.
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+
+	// Memory Request/Limit, defaults to the Environment's, then 256Mi
+	//+kubebuilder:validation:Optional
 	Memory string `json:"memory,omitempty"`
 
-	// Cpu Request/Limit, defaults to 200m
+	// Cpu Request/Limit, defaults to the Environment's, then 200m
 	//+kubebuilder:validation:Optional
-	// +kubebuilder:default:="200m"
 	Cpu string `json:"cpu,omitempty"`
 
-	// Port, defaults to 8080
 	//+kubebuilder:validation:Optional
-	// +kubebuilder:default:=8080
+	// Independent requests and limits, replacing Cpu and Memory when a profile is set and
+	// overriding them per resource otherwise
+	Resources *Resources `json:"resources,omitempty"`
+
+	// Port, defaults to 8080. Workers only expose a port when one is set.
+	//+kubebuilder:validation:Optional
 	Port *int32 `json:"port,omitempty"`
 
-	// Public Hostname, defaults to app name
+	//+kubebuilder:validation:Optional
+	// Run as a background worker that receives no traffic, defaults to false.
+	// Workers get no Service, VirtualService or DestinationRule, and any existing ones are removed.
+	Worker *bool `json:"worker,omitempty"`
+
+	// Public Hostname, defaults to app name under the platform domain
 	//+kubebuilder:validation:Optional
 	Hostname string `json:"hostname,omitempty"`
 
@@ -78,6 +119,22 @@ type AppSpec struct {
 	// Expose route through ingress gateway, defaults to true
 	Public *bool `json:"public,omitempty"`
 
+	//+kubebuilder:validation:Optional
+	// Callers allowed through the mesh, enforced by an Istio AuthorizationPolicy. Without it, any
+	// workload in the mesh can call the App. Requires mTLS and the sidecar.
+	Access *Access `json:"access,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Callers allowed to reach the App. Declaring callers or destinations, or running in an
+	// Environment with default-deny networking, isolates the App with a NetworkPolicy that only
+	// lets in these callers, and the ingress gateway when the App is public.
+	IngressFrom []NetworkPeer `json:"ingressFrom,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Destinations the App may reach. Once set, all other egress is denied, except DNS and the
+	// Istio control plane.
+	EgressTo []NetworkPeer `json:"egressTo,omitempty"`
+
 	//+kubebuilder:validation:Optional
 	// Node Selector
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
@@ -94,6 +151,18 @@ type AppSpec struct {
 	// Secrets to mount as environment variables
 	Secrets []string `json:"secrets,omitempty"`
 
+	//+kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Additional containers run alongside the application in every pod, such as log shippers or proxies
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Tasks run in order to completion before the application starts, such as migrations or cache warming
+	InitContainers []InitContainer `json:"initContainers,omitempty"`
+
 	//+kubebuilder:validation:Optional
 	// Annotations to add to all resources
 	Annotations map[string]string `json:"annotations,omitempty"`
@@ -106,25 +175,616 @@ type AppSpec struct {
 	// Config to store in configmap and mount as files
 	Config map[string]string `json:"config,omitempty"`
 
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="/config"
+	// Path to mount config files at, defaults to "/config"
+	ConfigMountPath string `json:"configMountPath,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Volumes mounted into the application and init containers
+	Volumes []Volume `json:"volumes,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Run every container with a read-only root filesystem, defaults to false.
+	// An emptyDir is mounted at /tmp unless a volume is already mounted there.
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+
 	//+kubebuilder:validation:Optional
 	// +kubebuilder:default:="tcp"
-	// Health Check type, defaults to "tcp"
+	// Health Check type, one of "tcp", "http", "exec" or "grpc", defaults to "tcp".
+	// Workers without a port skip every check but exec.
 	HealthCheckType string `json:"healthCheckType"`
 
 	//+kubebuilder:validation:Optional
 	// Endpoint for health check if set to Http
 	HealthCheckEndpoint string `json:"healthCheckEndpoint"`
+
+	//+kubebuilder:validation:Optional
+	// Command run inside the application container for health check if set to Exec
+	HealthCheckCommand []string `json:"healthCheckCommand,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Probe overrides, each defaulting to the health check above
+	Probes *Probes `json:"probes,omitempty"`
+}
+
+// Workload kinds an App can run as
+const (
+	WorkloadDeployment  = "Deployment"
+	WorkloadStatefulSet = "StatefulSet"
+	WorkloadJob         = "Job"
+	WorkloadCronJob     = "CronJob"
+)
+
+// Longest App names the batch workload kinds allow. A Job is named after the App with a 9
+// character hash suffix and its name becomes a label value, limited to 63 characters. The CronJob
+// controller appends 11 characters to a CronJob's name when it creates Jobs.
+const (
+	MaxJobAppNameLength     = 54
+	MaxCronJobAppNameLength = 52
+)
+
+// Labels identifying the App that created an object it cannot own, such as an object in
+// another namespace. kappa doesn't set them: whatever creates the object must. Objects of the
+// kinds the manager is started with in --cleanup-kinds are removed when the App is deleted.
+const (
+	AppNameLabel      = "kappa.io/app-name"
+	AppNamespaceLabel = "kappa.io/app-namespace"
+)
+
+// Condition types reported on App
+const (
+	// ConditionReady is true when every resource reconciled and the deployment is fully available
+	ConditionReady = "Ready"
+	// ConditionProgressing is true while a rollout of the deployment is underway
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is true when a reconcile step failed or the rollout is stuck
+	ConditionDegraded = "Degraded"
+	// ConditionTerminating reports the progress of cleanup while the App is being deleted
+	ConditionTerminating = "Terminating"
+	// ConditionWithinBudget is false while the App is held below its scale by its Environment's
+	// remaining budget
+	ConditionWithinBudget = "WithinBudget"
+
+	ConditionServiceAccountReconciled      = "ServiceAccountReconciled"
+	ConditionConfigMapReconciled           = "ConfigMapReconciled"
+	ConditionVolumeClaimsReconciled        = "VolumeClaimsReconciled"
+	ConditionDeploymentReconciled          = "DeploymentReconciled"
+	ConditionStatefulSetReconciled         = "StatefulSetReconciled"
+	ConditionJobReconciled                 = "JobReconciled"
+	ConditionCronJobReconciled             = "CronJobReconciled"
+	ConditionBlueGreenReconciled           = "BlueGreenReconciled"
+	ConditionAutoscalerReconciled          = "AutoscalerReconciled"
+	ConditionCanaryReconciled              = "CanaryReconciled"
+	ConditionDisruptionBudgetReconciled    = "DisruptionBudgetReconciled"
+	ConditionServiceReconciled             = "ServiceReconciled"
+	ConditionHeadlessServiceReconciled     = "HeadlessServiceReconciled"
+	ConditionVirtualServiceReconciled      = "VirtualServiceReconciled"
+	ConditionDestinationRuleReconciled     = "DestinationRuleReconciled"
+	ConditionNetworkPolicyReconciled       = "NetworkPolicyReconciled"
+	ConditionAuthorizationPolicyReconciled = "AuthorizationPolicyReconciled"
+)
+
+// Autoscaling configures the HorizontalPodAutoscaler for an App
+type Autoscaling struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=1
+	// Minimum number of instances, defaults to 1
+	MinInstances *int32 `json:"minInstances,omitempty"`
+
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:Minimum=1
+	// Maximum number of instances
+	MaxInstances int32 `json:"maxInstances"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Target average CPU utilization as a percentage of the request
+	TargetCpuUtilization *int32 `json:"targetCpuUtilization,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Target average memory utilization as a percentage of the request
+	TargetMemoryUtilization *int32 `json:"targetMemoryUtilization,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Additional pod, object or external metrics to scale on
+	Metrics []autoscalingv2beta2.MetricSpec `json:"metrics,omitempty"`
+}
+
+// Resources configures the application container's compute resources. Requests and limits are
+// applied on top of the profile, or on top of the App's Cpu and Memory when there is no profile.
+// A resource given only a request is limited to it, and one given only a limit requests it.
+type Resources struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=small;medium;large
+	// Preset requests and limits. Profiles limit cpu to twice the request and memory to the request.
+	Profile string `json:"profile,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Requests per resource, such as cpu, memory, ephemeral-storage or extended resources.
+	// The limit defaults to the request.
+	Requests v1.ResourceList `json:"requests,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Limits per resource, which must be at least the request. The request defaults to the limit.
+	Limits v1.ResourceList `json:"limits,omitempty"`
+}
+
+// Access restricts which workloads may call an App and what they may call. Callers matching any
+// ServiceAccount or Namespace are allowed, and the ingress gateway's ServiceAccount too when the
+// App is public.
+// Leaving both empty allows every caller, limited to Methods and Paths.
+type Access struct {
+	//+kubebuilder:validation:Optional
+	// ServiceAccounts of callers, as <namespace>/<name> or <name> in the App's namespace
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Namespaces whose workloads may call the App
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// HTTP methods callers may use, defaults to all
+	Methods []string `json:"methods,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Paths callers may request, with an optional * prefix or suffix, defaults to all
+	Paths []string `json:"paths,omitempty"`
+}
+
+// NetworkPeer is another App, or a range of addresses outside of the cluster. Exactly one of App
+// and Cidr must be set.
+type NetworkPeer struct {
+	//+kubebuilder:validation:Optional
+	// Name of the App
+	App string `json:"app,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Namespace of the App, defaults to this App's namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Range of addresses, such as 10.0.0.0/16
+	Cidr string `json:"cidr,omitempty"`
+}
+
+// Probes configures the application container's probes. Readiness defaults to checking every 10s
+// after 10s and failing after 12 attempts, liveness to checking after 120s and failing after one
+// attempt. There is no startup probe unless one is set.
+type Probes struct {
+	//+kubebuilder:validation:Optional
+	// Probe deciding whether the pod receives traffic
+	Readiness *Probe `json:"readiness,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Probe deciding whether the application container is restarted
+	Liveness *Probe `json:"liveness,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Probe holding off the other probes until it succeeds, for slow-starting applications.
+	// Defaults to allowing 30 failed checks, 10s apart.
+	Startup *Probe `json:"startup,omitempty"`
+}
+
+// Probe configures one of the application container's probes. Unset fields default to the
+// App's health check and the probe's usual timing.
+type Probe struct {
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=tcp;http;exec;grpc
+	// Type of check, defaults to healthCheckType. gRPC checks run grpc_health_probe,
+	// which must be installed in the application image.
+	Type string `json:"type,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Port to check, defaults to the App's port
+	Port *int32 `json:"port,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Path of an http check, defaults to healthCheckEndpoint
+	Path string `json:"path,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=HTTP;HTTPS
+	// Scheme of an http check, defaults to HTTP
+	Scheme v1.URIScheme `json:"scheme,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Headers sent with an http check
+	Headers []v1.HTTPHeader `json:"headers,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Command of an exec check, defaults to healthCheckCommand
+	Command []string `json:"command,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Service name reported by a grpc check, defaults to the server's overall health
+	Service string `json:"service,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// Seconds before the first check
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Seconds between checks, defaults to 10
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Seconds before a check times out, defaults to 1 for tcp checks and 10 otherwise
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Consecutive successes after a failure before the probe passes, defaults to 1.
+	// Must be 1 for liveness and startup probes.
+	SuccessThreshold *int32 `json:"successThreshold,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Consecutive failures before the probe fails
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+// Sidecar is an additional container run in every pod of an App. Sidecars get the same
+// security context as the application container.
+type Sidecar struct {
+	//+kubebuilder:validation:Required
+	// Name of the container, unique within the pod
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Required
+	// Image of the sidecar, including its tag or digest
+	Image string `json:"image"`
+
+	//+kubebuilder:validation:Optional
+	// Entrypoint override
+	Command []string `json:"command,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Arguments to the entrypoint
+	Args []string `json:"args,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Environment Variables
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Secrets to mount as environment variables
+	Secrets []string `json:"secrets,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Ports exposed by the sidecar
+	Ports []v1.ContainerPort `json:"ports,omitempty"`
+
+	// Memory Request/Limit, defaults to 64Mi
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="64Mi"
+	Memory string `json:"memory,omitempty"`
+
+	// Cpu Request/Limit, defaults to 50m
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="50m"
+	Cpu string `json:"cpu,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Probe deciding whether the pod receives traffic
+	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Probe deciding whether the sidecar is restarted
+	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
+}
+
+// InitContainer runs a task to completion before the application container starts. It runs the
+// App's image with the App's environment and config unless told otherwise, and gets the same
+// security context as the application container.
+type InitContainer struct {
+	//+kubebuilder:validation:Required
+	// Name of the container, unique within the pod
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	// Image to run, including its tag or digest, defaults to the App's image and version
+	Image string `json:"image,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Entrypoint override
+	Command []string `json:"command,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Arguments to the entrypoint
+	Args []string `json:"args,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Environment Variables, added to the App's
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Secrets to mount as environment variables, added to the App's
+	Secrets []string `json:"secrets,omitempty"`
+
+	// Memory Request/Limit, defaults to the App's
+	//+kubebuilder:validation:Optional
+	Memory string `json:"memory,omitempty"`
+
+	// Cpu Request/Limit, defaults to the App's
+	//+kubebuilder:validation:Optional
+	Cpu string `json:"cpu,omitempty"`
+}
+
+// Volume is mounted into an App's pods. Exactly one of EmptyDir, Claim and ExistingClaim must be set.
+type Volume struct {
+	//+kubebuilder:validation:Required
+	// Name of the volume, unique within the App
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Required
+	// Path to mount the volume at
+	MountPath string `json:"mountPath"`
+
+	//+kubebuilder:validation:Optional
+	// Mount the volume read-only, defaults to false
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Scratch space that lives as long as the pod
+	EmptyDir *EmptyDirVolume `json:"emptyDir,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// PersistentVolumeClaim created and owned by the App, named <app>-<volume>.
+	// The claim and its data are deleted when the volume is removed from the App, and the claim
+	// can't be changed once created. StatefulSet Apps get a claim per instance instead, and
+	// their claim volumes can't be added or removed either.
+	Claim *ClaimVolume `json:"claim,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Name of an existing PersistentVolumeClaim to mount
+	ExistingClaim string `json:"existingClaim,omitempty"`
+}
+
+// EmptyDirVolume configures scratch space for an App's pods
+type EmptyDirVolume struct {
+	//+kubebuilder:validation:Optional
+	// Storage medium, "Memory" for tmpfs, defaults to the node's disk
+	Medium v1.StorageMedium `json:"medium,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Maximum size of the volume
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// ClaimVolume configures a PersistentVolumeClaim created for an App
+type ClaimVolume struct {
+	//+kubebuilder:validation:Required
+	// Requested size of the volume
+	Size resource.Quantity `json:"size"`
+
+	//+kubebuilder:validation:Optional
+	// Storage class to provision the volume from, defaults to the cluster default
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Access modes of the volume, defaults to ReadWriteOnce. A ReadWriteOnce volume can only be
+	// attached to one node, so it is replaced rather than rolled for Deployment Apps, which may
+	// then only run a single instance.
+	AccessModes []v1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+// SingleNode reports whether the claim's volume can only be attached to one node at a time
+func (c *ClaimVolume) SingleNode() bool {
+	for _, mode := range c.AccessModes {
+		if mode == v1.ReadWriteMany || mode == v1.ReadOnlyMany {
+			return false
+		}
+	}
+	return true
+}
+
+// Job configures how an App with the Job or CronJob workload kind runs. A Job runs once for
+// every change to its pod template. Istio sidecars are not injected into job pods, since they
+// would keep the pods running after the work is done.
+type Job struct {
+	//+kubebuilder:validation:Optional
+	// Cron schedule, required for the CronJob workload kind
+	Schedule string `json:"schedule,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=Allow;Forbid;Replace
+	// +kubebuilder:default:="Forbid"
+	// How overlapping scheduled runs are handled, defaults to Forbid
+	ConcurrencyPolicy batchv1beta1.ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Stop scheduling new runs, defaults to false
+	Suspend *bool `json:"suspend,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// +kubebuilder:default:=3
+	// Retries before a run is marked failed, defaults to 3
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// Time in seconds a run may take before it is terminated
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// Finished successful runs of a CronJob to keep
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// Finished failed runs of a CronJob to keep
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+}
+
+// Canary runs a second deployment of a new version alongside the current one and
+// gradually shifts traffic to it. Promote the canary by setting the App's version to
+// the canary version and removing the canary. While a canary runs, an autoscaled App's
+// resource metrics are averaged over the canary's pods too, since the App's deployment
+// selects them, but the canary is never scaled by the autoscaler.
+type Canary struct {
+	//+kubebuilder:validation:Required
+	// Version to release as a canary
+	Version string `json:"version"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=1
+	// Instances of the canary version, defaults to 1
+	Instances *int32 `json:"instances,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Percentage of traffic sent to the canary at each step, defaults to 10, 25, 50 and 100
+	Steps []int32 `json:"steps,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="5m"
+	// Time to wait at each step before shifting more traffic, defaults to 5m
+	StepInterval *metav1.Duration `json:"stepInterval,omitempty"`
+}
+
+// CanaryStatus reports the progress of a canary release
+type CanaryStatus struct {
+	// Version being released as a canary
+	Version string `json:"version"`
+
+	// Index of the current step
+	Step int32 `json:"step"`
+
+	// Percentage of traffic sent to the canary
+	CanaryWeight int32 `json:"canaryWeight"`
+
+	// Percentage of traffic sent to the current version
+	StableWeight int32 `json:"stableWeight"`
+
+	// Time the current step started
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+
+	// Complete is true once the final step has been reached and the canary can be promoted
+	Complete bool `json:"complete,omitempty"`
+}
+
+// PromoteAnnotation promotes a blue/green preview when set to the App's version
+const PromoteAnnotation = "kappa.io/promote"
+
+// BlueGreen runs each new version in a second deployment that only receives preview
+// traffic until it is promoted, at which point live traffic switches over at once.
+type BlueGreen struct {
+	//+kubebuilder:validation:Optional
+	// Promotes the preview once set to the App's version. The kappa.io/promote annotation may be used instead.
+	PromotedVersion string `json:"promotedVersion,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Hostname the preview is exposed on, defaults to the app name suffixed with -preview under the platform domain
+	PreviewHostname string `json:"previewHostname,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="x-kappa-preview"
+	// Requests with this header set to "true" are routed to the preview, defaults to "x-kappa-preview"
+	PreviewHeader string `json:"previewHeader,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="30m"
+	// Time the previous version is kept running after a promotion for instant rollback, defaults to 30m
+	ScaleDownDelay *metav1.Duration `json:"scaleDownDelay,omitempty"`
+}
+
+// BlueGreenStatus reports which color receives live and preview traffic
+type BlueGreenStatus struct {
+	// Color receiving live traffic
+	ActiveColor string `json:"activeColor"`
+
+	// Version running in the active color
+	ActiveVersion string `json:"activeVersion"`
+
+	// Whether the active color has rolled out and receives live traffic. Until then traffic
+	// stays on the rolling deployment.
+	Live bool `json:"live,omitempty"`
+
+	// Color receiving preview traffic, if any
+	PreviewColor string `json:"previewColor,omitempty"`
+
+	// Version running in the preview color, either awaiting promotion or kept for rollback
+	PreviewVersion string `json:"previewVersion,omitempty"`
+
+	// Time the preview color is removed, set once it only holds the previous version
+	ScaleDownTime *metav1.Time `json:"scaleDownTime,omitempty"`
+}
+
+// DisruptionBudget overrides the PodDisruptionBudget generated for an App.
+// Only one of MinAvailable and MaxUnavailable may be set.
+type DisruptionBudget struct {
+	//+kubebuilder:validation:Optional
+	// Number or percentage of instances that must remain available during a disruption
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Number or percentage of instances that may be unavailable during a disruption
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 }
 
 // AppStatus defines the observed state of App
 type AppStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	appsv1.DeploymentStatus `json:",inline"`
+
+	// Generation of the App most recently reconciled
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describing the state of the App and each resource it manages
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// Error from the last failed reconcile step, empty once reconciliation succeeds
+	LastError string `json:"lastError,omitempty"`
+
+	// Status of the App's deployment
+	Deployment *appsv1.DeploymentStatus `json:"deployment,omitempty"`
+
+	// Status of the App's StatefulSet, when it runs as one
+	StatefulSet *appsv1.StatefulSetStatus `json:"statefulSet,omitempty"`
+
+	// Status of the App's current Job, when it runs as one
+	Job *batchv1.JobStatus `json:"job,omitempty"`
+
+	// Status of the App's CronJob, when it runs as one
+	CronJob *batchv1beta1.CronJobStatus `json:"cronJob,omitempty"`
+
+	// Progress of the active canary release
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// State of blue/green releases
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
+	// Spec the App was reconciled with, after merging in its Environment's App defaults
+	EffectiveSpec *AppSpec `json:"effectiveSpec,omitempty"`
+
+	// Fields of the effective spec that came from the Environment, such as "cpu",
+	// "nodeSelector.zone" or "secrets[shared-db]"
+	Inherited []string `json:"inherited,omitempty"`
+
+	// Instances the App is held at while its Environment's budget has no room for more, unset
+	// when it fits at its largest scale
+	AllowedInstances *int32 `json:"allowedInstances,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Available",type=integer,JSONPath=`.status.deployment.availableReplicas`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // App is the Schema for the apps API
 type App struct {
@@ -0,0 +1,577 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var applog = logf.Log.WithName("app-resource")
+
+// Health check types supported by the App reconciler
+const (
+	HealthCheckTCP  = "tcp"
+	HealthCheckHTTP = "http"
+	HealthCheckExec = "exec"
+	HealthCheckGRPC = "grpc"
+)
+
+func (r *App) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-kapp-kappa-io-v1alpha1-app,mutating=false,failurePolicy=fail,sideEffects=None,groups=kapp.kappa.io,resources=apps,verbs=create;update,versions=v1alpha1,name=vapp.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &App{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *App) ValidateCreate() error {
+	applog.Info("validate create", "name", r.Name)
+
+	return r.validateApp(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *App) ValidateUpdate(old runtime.Object) error {
+	applog.Info("validate update", "name", r.Name)
+
+	// Let deletions and metadata changes such as finalizer updates through, so an App whose spec
+	// no longer passes validation can still be cleaned up
+	if !r.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	oldApp, ok := old.(*App)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected an App but got a %T", old))
+	}
+	if equality.Semantic.DeepEqual(oldApp.Spec, r.Spec) {
+		return nil
+	}
+
+	return r.validateApp(oldApp)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *App) ValidateDelete() error {
+	applog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+// ValidateSpec validates the App's spec as the webhook does. The webhook only sees the spec as
+// written, so the controller validates the effective spec with Environment defaults merged in.
+func (r *App) ValidateSpec() error {
+	return r.validateApp(nil)
+}
+
+// validateApp validates the App's spec, and what may not change since old when it is being updated
+func (r *App) validateApp(old *App) error {
+	allErrs := r.validateAppSpec()
+	if old != nil {
+		allErrs = append(allErrs, r.validateImmutableFields(old)...)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: GroupVersion.Group, Kind: "App"},
+		r.Name, allErrs)
+}
+
+func (r *App) validateAppSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	allErrs = append(allErrs, validateQuantity(r.Spec.Cpu, specPath.Child("cpu"))...)
+	allErrs = append(allErrs, validateQuantity(r.Spec.Memory, specPath.Child("memory"))...)
+	allErrs = append(allErrs, r.validateResources(specPath.Child("resources"))...)
+
+	switch r.Spec.HealthCheckType {
+	case "", HealthCheckTCP, HealthCheckGRPC:
+	case HealthCheckHTTP:
+		endpointPath := specPath.Child("healthCheckEndpoint")
+		if r.Spec.HealthCheckEndpoint == "" {
+			allErrs = append(allErrs, field.Required(endpointPath, "required when healthCheckType is http"))
+		} else if !strings.HasPrefix(r.Spec.HealthCheckEndpoint, "/") {
+			allErrs = append(allErrs, field.Invalid(endpointPath, r.Spec.HealthCheckEndpoint, "must be an absolute path"))
+		}
+	case HealthCheckExec:
+		if len(r.Spec.HealthCheckCommand) == 0 {
+			allErrs = append(allErrs, field.Required(specPath.Child("healthCheckCommand"), "required when healthCheckType is exec"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("healthCheckType"),
+			r.Spec.HealthCheckType, []string{HealthCheckTCP, HealthCheckHTTP, HealthCheckExec, HealthCheckGRPC}))
+	}
+
+	allErrs = append(allErrs, r.validateProbes(specPath.Child("probes"))...)
+
+	if r.Spec.Worker != nil && *r.Spec.Worker {
+		// Workers receive no traffic, so there is nothing to route or shift
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"canary", r.Spec.Canary != nil},
+			{"blueGreen", r.Spec.BlueGreen != nil},
+			{"hostname", r.Spec.Hostname != ""},
+			{"public", r.Spec.Public != nil && *r.Spec.Public},
+			{"access", r.Spec.Access != nil},
+		} {
+			if f.set {
+				allErrs = append(allErrs, field.Forbidden(specPath.Child(f.name), "is not supported for workers"))
+			}
+		}
+	}
+
+	if as := r.Spec.Autoscaling; as != nil && as.MinInstances != nil && *as.MinInstances > as.MaxInstances {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("autoscaling", "maxInstances"),
+			as.MaxInstances, "must be greater than or equal to minInstances"))
+	}
+
+	if c := r.Spec.Canary; c != nil {
+		stepsPath := specPath.Child("canary", "steps")
+		for i, step := range c.Steps {
+			if step < 1 || step > 100 {
+				allErrs = append(allErrs, field.Invalid(stepsPath.Index(i), step, "must be between 1 and 100"))
+			} else if i > 0 && step <= c.Steps[i-1] {
+				allErrs = append(allErrs, field.Invalid(stepsPath.Index(i), step, "must be greater than the previous step"))
+			}
+		}
+	}
+
+	switch r.Spec.WorkloadKind {
+	case WorkloadStatefulSet:
+		if r.Spec.Canary != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("canary"), "is not supported for StatefulSet workloads"))
+		}
+		if r.Spec.BlueGreen != nil {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("blueGreen"), "is not supported for StatefulSet workloads"))
+		}
+	case WorkloadJob, WorkloadCronJob:
+		if r.Spec.WorkloadKind == WorkloadCronJob && (r.Spec.Job == nil || r.Spec.Job.Schedule == "") {
+			allErrs = append(allErrs, field.Required(specPath.Child("job", "schedule"), "required for the CronJob workload kind"))
+		}
+		maxNameLength := MaxJobAppNameLength
+		if r.Spec.WorkloadKind == WorkloadCronJob {
+			maxNameLength = MaxCronJobAppNameLength
+		}
+		if len(r.Name) > maxNameLength {
+			allErrs = append(allErrs, field.TooLong(field.NewPath("metadata", "name"), r.Name, maxNameLength))
+		}
+		// Batch work runs to completion, so nothing that assumes long-running instances applies
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{
+			{"autoscaling", r.Spec.Autoscaling != nil},
+			{"canary", r.Spec.Canary != nil},
+			{"blueGreen", r.Spec.BlueGreen != nil},
+			{"sidecars", len(r.Spec.Sidecars) > 0},
+		} {
+			if f.set {
+				allErrs = append(allErrs, field.Forbidden(specPath.Child(f.name),
+					fmt.Sprintf("is not supported for %s workloads", r.Spec.WorkloadKind)))
+			}
+		}
+	}
+
+	if r.Spec.Canary != nil && r.Spec.BlueGreen != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("blueGreen"), "may not be set together with canary"))
+	}
+
+	if bg := r.Spec.BlueGreen; bg != nil {
+		if bg.PreviewHostname != "" {
+			for _, msg := range validation.IsDNS1123Subdomain(bg.PreviewHostname) {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("blueGreen", "previewHostname"), bg.PreviewHostname, msg))
+			}
+		}
+		if bg.PreviewHeader != "" {
+			for _, msg := range validation.IsHTTPHeaderName(bg.PreviewHeader) {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("blueGreen", "previewHeader"), bg.PreviewHeader, msg))
+			}
+		}
+	}
+
+	if db := r.Spec.DisruptionBudget; db != nil && db.MinAvailable != nil && db.MaxUnavailable != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("disruptionBudget", "maxUnavailable"),
+			"may not be set together with minAvailable"))
+	}
+
+	allErrs = append(allErrs, r.validateSidecars(specPath.Child("sidecars"))...)
+	allErrs = append(allErrs, r.validateInitContainers(specPath.Child("initContainers"))...)
+	allErrs = append(allErrs, r.validateVolumes(specPath.Child("volumes"))...)
+	allErrs = append(allErrs, r.validateAccess(specPath.Child("access"))...)
+	allErrs = append(allErrs, validateNetworkPeers(r.Spec.IngressFrom, specPath.Child("ingressFrom"))...)
+	allErrs = append(allErrs, validateNetworkPeers(r.Spec.EgressTo, specPath.Child("egressTo"))...)
+
+	if r.Spec.Hostname != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(r.Spec.Hostname) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("hostname"), r.Spec.Hostname, msg))
+		}
+	}
+
+	allErrs = append(allErrs, metav1validation.ValidateLabels(r.Spec.Labels, specPath.Child("labels"))...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(r.Spec.NodeSelector, specPath.Child("nodeSelector"))...)
+	allErrs = append(allErrs, apivalidation.ValidateAnnotations(r.Spec.Annotations, specPath.Child("annotations"))...)
+
+	return allErrs
+}
+
+// validateImmutableFields rejects changes to what the generated resources can't change once
+// created: the workload kind, claim volumes, and which claim volumes a StatefulSet has
+func (r *App) validateImmutableFields(old *App) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	kind, oldKind := r.Spec.WorkloadKind, old.Spec.WorkloadKind
+	if kind == "" {
+		kind = WorkloadDeployment
+	}
+	if oldKind == "" {
+		oldKind = WorkloadDeployment
+	}
+	allErrs = append(allErrs, apivalidation.ValidateImmutableField(kind, oldKind, specPath.Child("workloadKind"))...)
+
+	oldClaims := make(map[string]*ClaimVolume)
+	for _, volume := range old.Spec.Volumes {
+		if volume.Claim != nil {
+			oldClaims[volume.Name] = volume.Claim
+		}
+	}
+	claims := 0
+	for i, volume := range r.Spec.Volumes {
+		if volume.Claim == nil {
+			continue
+		}
+		claims++
+		claimPath := specPath.Child("volumes").Index(i).Child("claim")
+		oldClaim, ok := oldClaims[volume.Name]
+		if !ok {
+			if kind == WorkloadStatefulSet {
+				allErrs = append(allErrs, field.Forbidden(claimPath, "claim volumes may not be added to StatefulSet Apps"))
+			}
+			continue
+		}
+		allErrs = append(allErrs, apivalidation.ValidateImmutableField(volume.Claim, oldClaim, claimPath)...)
+	}
+	if kind == WorkloadStatefulSet && claims < len(oldClaims) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("volumes"), "claim volumes may not be removed from StatefulSet Apps"))
+	}
+
+	return allErrs
+}
+
+// validateResources requires every limit to be at least the request for the same resource,
+// including those coming from the profile or Cpu and Memory. Invalid Cpu and Memory are reported
+// on their own fields.
+func (r *App) validateResources(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	requirements, err := r.Spec.ResourceRequirements()
+	if err != nil {
+		return allErrs
+	}
+	for name, request := range requirements.Requests {
+		if limit, ok := requirements.Limits[name]; ok && limit.Cmp(request) < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limits").Key(string(name)), limit.String(),
+				fmt.Sprintf("must be greater than or equal to the %s request of %s", name, request.String())))
+		}
+	}
+	return allErrs
+}
+
+// validateProbes requires every probe to have what its type needs, after falling back to the App's health check
+func (r *App) validateProbes(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.Probes == nil {
+		return allErrs
+	}
+
+	for _, p := range []struct {
+		name  string
+		probe *Probe
+	}{
+		{"readiness", r.Spec.Probes.Readiness},
+		{"liveness", r.Spec.Probes.Liveness},
+		{"startup", r.Spec.Probes.Startup},
+	} {
+		if p.probe == nil {
+			continue
+		}
+		probePath := fldPath.Child(p.name)
+
+		probeType := p.probe.Type
+		if probeType == "" {
+			probeType = r.Spec.HealthCheckType
+		}
+		switch probeType {
+		case HealthCheckHTTP:
+			path := p.probe.Path
+			if path == "" {
+				path = r.Spec.HealthCheckEndpoint
+			}
+			if !strings.HasPrefix(path, "/") {
+				allErrs = append(allErrs, field.Invalid(probePath.Child("path"), path, "must be an absolute path"))
+			}
+			for i, header := range p.probe.Headers {
+				for _, msg := range validation.IsHTTPHeaderName(header.Name) {
+					allErrs = append(allErrs, field.Invalid(probePath.Child("headers").Index(i).Child("name"), header.Name, msg))
+				}
+			}
+		case HealthCheckExec:
+			if len(p.probe.Command) == 0 && len(r.Spec.HealthCheckCommand) == 0 {
+				allErrs = append(allErrs, field.Required(probePath.Child("command"), "required for exec probes"))
+			}
+		}
+
+		if p.name != "readiness" && p.probe.SuccessThreshold != nil && *p.probe.SuccessThreshold != 1 {
+			allErrs = append(allErrs, field.Invalid(probePath.Child("successThreshold"), *p.probe.SuccessThreshold,
+				fmt.Sprintf("must be 1 for %s probes", p.name)))
+		}
+	}
+
+	return allErrs
+}
+
+// validateSidecars requires unique container names and ports that don't collide with the application's
+func (r *App) validateSidecars(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{r.Name: true}
+	ports := make(map[int32]bool)
+	if r.Spec.Port != nil {
+		ports[*r.Spec.Port] = true
+	} else if r.Spec.Worker == nil || !*r.Spec.Worker {
+		ports[8080] = true
+	}
+
+	for i, sidecar := range r.Spec.Sidecars {
+		idxPath := fldPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(sidecar.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), sidecar.Name, msg))
+		}
+		if names[sidecar.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), sidecar.Name))
+		}
+		names[sidecar.Name] = true
+
+		if sidecar.Image == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("image"), ""))
+		}
+		allErrs = append(allErrs, validateQuantity(sidecar.Cpu, idxPath.Child("cpu"))...)
+		allErrs = append(allErrs, validateQuantity(sidecar.Memory, idxPath.Child("memory"))...)
+
+		for j, port := range sidecar.Ports {
+			if ports[port.ContainerPort] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("ports").Index(j).Child("containerPort"), port.ContainerPort))
+			}
+			ports[port.ContainerPort] = true
+		}
+	}
+
+	return allErrs
+}
+
+// validateInitContainers requires container names that are unique across the whole pod
+func (r *App) validateInitContainers(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{r.Name: true}
+	for _, sidecar := range r.Spec.Sidecars {
+		names[sidecar.Name] = true
+	}
+	for i, c := range r.Spec.InitContainers {
+		idxPath := fldPath.Index(i)
+		for _, msg := range validation.IsDNS1123Label(c.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), c.Name, msg))
+		}
+		if names[c.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), c.Name))
+		}
+		names[c.Name] = true
+
+		allErrs = append(allErrs, validateQuantity(c.Cpu, idxPath.Child("cpu"))...)
+		allErrs = append(allErrs, validateQuantity(c.Memory, idxPath.Child("memory"))...)
+	}
+	return allErrs
+}
+
+// validateVolumes requires unique names and mount paths and exactly one source per volume
+func (r *App) validateVolumes(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := make(map[string]bool)
+	paths := make(map[string]bool)
+	if len(r.Spec.Config) > 0 {
+		paths[r.Spec.ConfigMountPath] = true
+	}
+
+	for i, volume := range r.Spec.Volumes {
+		idxPath := fldPath.Index(i)
+		// Volume names also name the generated claim, so they must fit in an object name
+		for _, msg := range validation.IsDNS1123Label(volume.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), volume.Name, msg))
+		}
+		if names[volume.Name] || volume.Name == "config" || volume.Name == "tmp" {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), volume.Name))
+		}
+		names[volume.Name] = true
+
+		if !strings.HasPrefix(volume.MountPath, "/") {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("mountPath"), volume.MountPath, "must be an absolute path"))
+		} else if paths[volume.MountPath] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("mountPath"), volume.MountPath))
+		}
+		paths[volume.MountPath] = true
+
+		sources := 0
+		if volume.EmptyDir != nil {
+			sources++
+		}
+		if volume.Claim != nil {
+			sources++
+		}
+		if volume.ExistingClaim != "" {
+			sources++
+		}
+		if sources != 1 {
+			allErrs = append(allErrs, field.Invalid(idxPath, volume.Name,
+				"exactly one of emptyDir, claim and existingClaim must be set"))
+		}
+
+		// Pods of a second instance, a canary or a color land on other nodes and can't attach the volume
+		if volume.Claim != nil && volume.Claim.SingleNode() && r.multipleDeploymentInstances() {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("claim", "accessModes"), volume.Claim.AccessModes,
+				"ReadWriteOnce claims may only be mounted by a single instance; use a StatefulSet or a ReadWriteMany claim"))
+		}
+	}
+
+	return allErrs
+}
+
+// multipleDeploymentInstances reports whether a Deployment App may run more than one pod at a
+// time outside of rollouts, through its instances, autoscaling, a canary or blue/green
+func (r *App) multipleDeploymentInstances() bool {
+	if r.Spec.WorkloadKind != "" && r.Spec.WorkloadKind != WorkloadDeployment {
+		return false
+	}
+	return (r.Spec.Instances != nil && *r.Spec.Instances > 1) ||
+		r.Spec.Autoscaling != nil || r.Spec.Canary != nil || r.Spec.BlueGreen != nil
+}
+
+// validateQuantity rejects values resource.MustParse would panic on
+func validateQuantity(value string, fldPath *field.Path) field.ErrorList {
+	if value == "" {
+		return nil
+	}
+	if _, err := resource.ParseQuantity(value); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, value, err.Error())}
+	}
+	return nil
+}
+
+// validateNetworkPeers requires each peer to be either an App or a valid CIDR
+func validateNetworkPeers(peers []NetworkPeer, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, peer := range peers {
+		idxPath := fldPath.Index(i)
+		if (peer.App == "") == (peer.Cidr == "") {
+			allErrs = append(allErrs, field.Invalid(idxPath, peer, "exactly one of app and cidr must be set"))
+			continue
+		}
+		if peer.Cidr != "" {
+			if _, _, err := net.ParseCIDR(peer.Cidr); err != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("cidr"), peer.Cidr, err.Error()))
+			}
+			if peer.Namespace != "" {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("namespace"), "may only be set for app peers"))
+			}
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Label(peer.App) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("app"), peer.App, msg))
+		}
+		if peer.Namespace != "" {
+			for _, msg := range validation.IsDNS1123Label(peer.Namespace) {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("namespace"), peer.Namespace, msg))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateAccess requires valid ServiceAccount and Namespace names, upper case methods and absolute
+// paths. Access rules match callers by their mesh identity, so they need mTLS and a sidecar.
+func (r *App) validateAccess(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	access := r.Spec.Access
+	if access == nil {
+		return allErrs
+	}
+	if r.Spec.DisableMtls != nil && *r.Spec.DisableMtls {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not be set when disableMtls is true"))
+	}
+	if r.Spec.DisableSidecar != nil && *r.Spec.DisableSidecar {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "may not be set when disableSidecar is true"))
+	}
+
+	for i, sa := range access.ServiceAccounts {
+		idxPath := fldPath.Child("serviceAccounts").Index(i)
+		parts := strings.Split(sa, "/")
+		if len(parts) > 2 {
+			allErrs = append(allErrs, field.Invalid(idxPath, sa, "must be <namespace>/<name> or <name>"))
+			continue
+		}
+		for _, part := range parts {
+			for _, msg := range validation.IsDNS1123Subdomain(part) {
+				allErrs = append(allErrs, field.Invalid(idxPath, sa, msg))
+			}
+		}
+	}
+	for i, ns := range access.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("namespaces").Index(i), ns, msg))
+		}
+	}
+	for i, method := range access.Methods {
+		if method == "" || method != strings.ToUpper(method) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("methods").Index(i), method, "must be an upper case HTTP method"))
+		}
+	}
+	for i, path := range access.Paths {
+		if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "*") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("paths").Index(i), path, "must be an absolute path or start with *"))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,591 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// errorFields returns the paths of the fields errs were reported on, in order
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func boolPtr(b bool) *bool { return &b }
+
+func newApp(spec AppSpec) *App {
+	return &App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}, Spec: spec}
+}
+
+func TestValidateAppSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		app    *App
+		fields []string
+	}{
+		{name: "empty spec", app: newApp(AppSpec{})},
+		{
+			name:   "invalid cpu and memory",
+			app:    newApp(AppSpec{Cpu: "lots", Memory: "1Gx"}),
+			fields: []string{"spec.cpu", "spec.memory"},
+		},
+		{
+			name:   "http health check without endpoint",
+			app:    newApp(AppSpec{HealthCheckType: HealthCheckHTTP}),
+			fields: []string{"spec.healthCheckEndpoint"},
+		},
+		{
+			name:   "http health check with relative endpoint",
+			app:    newApp(AppSpec{HealthCheckType: HealthCheckHTTP, HealthCheckEndpoint: "healthz"}),
+			fields: []string{"spec.healthCheckEndpoint"},
+		},
+		{
+			name:   "exec health check without command",
+			app:    newApp(AppSpec{HealthCheckType: HealthCheckExec}),
+			fields: []string{"spec.healthCheckCommand"},
+		},
+		{
+			name:   "unknown health check type",
+			app:    newApp(AppSpec{HealthCheckType: "udp"}),
+			fields: []string{"spec.healthCheckType"},
+		},
+		{
+			name: "worker with routing",
+			app: newApp(AppSpec{
+				Worker:   boolPtr(true),
+				Canary:   &Canary{Version: "v2"},
+				Hostname: "web.example.com",
+				Public:   boolPtr(true),
+				Access:   &Access{},
+			}),
+			fields: []string{"spec.canary", "spec.hostname", "spec.public", "spec.access"},
+		},
+		{
+			name:   "autoscaling minimum above maximum",
+			app:    newApp(AppSpec{Autoscaling: &Autoscaling{MinInstances: int32Ptr(3), MaxInstances: 2}}),
+			fields: []string{"spec.autoscaling.maxInstances"},
+		},
+		{
+			name:   "canary steps out of range and not increasing",
+			app:    newApp(AppSpec{Canary: &Canary{Version: "v2", Steps: []int32{0, 50, 40, 101}}}),
+			fields: []string{"spec.canary.steps[0]", "spec.canary.steps[2]", "spec.canary.steps[3]"},
+		},
+		{
+			name:   "StatefulSet with canary and blue/green",
+			app:    newApp(AppSpec{WorkloadKind: WorkloadStatefulSet, Canary: &Canary{Version: "v2"}, BlueGreen: &BlueGreen{}}),
+			fields: []string{"spec.canary", "spec.blueGreen", "spec.blueGreen"},
+		},
+		{
+			name:   "CronJob without schedule",
+			app:    newApp(AppSpec{WorkloadKind: WorkloadCronJob}),
+			fields: []string{"spec.job.schedule"},
+		},
+		{
+			name:   "Job with long-running settings",
+			app:    newApp(AppSpec{WorkloadKind: WorkloadJob, Autoscaling: &Autoscaling{MaxInstances: 2}, Sidecars: []Sidecar{{Name: "proxy", Image: "envoy"}}}),
+			fields: []string{"spec.autoscaling", "spec.sidecars"},
+		},
+		{
+			name: "Job name too long",
+			app: &App{
+				ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", MaxJobAppNameLength+1)},
+				Spec:       AppSpec{WorkloadKind: WorkloadJob},
+			},
+			fields: []string{"metadata.name"},
+		},
+		{
+			name: "CronJob name too long",
+			app: &App{
+				ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", MaxCronJobAppNameLength+1)},
+				Spec:       AppSpec{WorkloadKind: WorkloadCronJob, Job: &Job{Schedule: "@daily"}},
+			},
+			fields: []string{"metadata.name"},
+		},
+		{
+			name:   "invalid blue/green preview routing",
+			app:    newApp(AppSpec{BlueGreen: &BlueGreen{PreviewHostname: "Preview_Host", PreviewHeader: "x preview"}}),
+			fields: []string{"spec.blueGreen.previewHostname", "spec.blueGreen.previewHeader"},
+		},
+		{
+			name: "disruption budget with both bounds",
+			app: newApp(AppSpec{DisruptionBudget: &DisruptionBudget{
+				MinAvailable:   &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+				MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+			}}),
+			fields: []string{"spec.disruptionBudget.maxUnavailable"},
+		},
+		{
+			name:   "invalid hostname",
+			app:    newApp(AppSpec{Hostname: "Web_Host"}),
+			fields: []string{"spec.hostname"},
+		},
+		{
+			name:   "invalid labels and node selector",
+			app:    newApp(AppSpec{Labels: map[string]string{"team": "a b"}, NodeSelector: map[string]string{"-zone": "a"}}),
+			fields: []string{"spec.labels", "spec.nodeSelector"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(tt.app.validateAppSpec()); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateSidecars(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "valid sidecar", spec: AppSpec{Sidecars: []Sidecar{{Name: "proxy", Image: "envoy", Cpu: "100m"}}}},
+		{
+			name:   "invalid name and missing image",
+			spec:   AppSpec{Sidecars: []Sidecar{{Name: "Proxy"}}},
+			fields: []string{"spec.sidecars[0].name", "spec.sidecars[0].image"},
+		},
+		{
+			name:   "named after the App",
+			spec:   AppSpec{Sidecars: []Sidecar{{Name: "web", Image: "envoy"}}},
+			fields: []string{"spec.sidecars[0].name"},
+		},
+		{
+			name:   "duplicate names",
+			spec:   AppSpec{Sidecars: []Sidecar{{Name: "proxy", Image: "envoy"}, {Name: "proxy", Image: "envoy"}}},
+			fields: []string{"spec.sidecars[1].name"},
+		},
+		{
+			name:   "invalid quantities",
+			spec:   AppSpec{Sidecars: []Sidecar{{Name: "proxy", Image: "envoy", Cpu: "fast", Memory: "big"}}},
+			fields: []string{"spec.sidecars[0].cpu", "spec.sidecars[0].memory"},
+		},
+		{
+			name:   "port of the default application port",
+			spec:   AppSpec{Sidecars: []Sidecar{{Name: "proxy", Image: "envoy", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}}}},
+			fields: []string{"spec.sidecars[0].ports[0].containerPort"},
+		},
+		{
+			name: "port of the default application port on a worker",
+			spec: AppSpec{Worker: boolPtr(true), Sidecars: []Sidecar{{Name: "proxy", Image: "envoy", Ports: []corev1.ContainerPort{{ContainerPort: 8080}}}}},
+		},
+		{
+			name:   "port of the application",
+			spec:   AppSpec{Port: int32Ptr(9000), Sidecars: []Sidecar{{Name: "proxy", Image: "envoy", Ports: []corev1.ContainerPort{{ContainerPort: 9000}}}}},
+			fields: []string{"spec.sidecars[0].ports[0].containerPort"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.spec)
+			if got := errorFields(app.validateSidecars(field.NewPath("spec", "sidecars"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateInitContainers(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "valid init container", spec: AppSpec{InitContainers: []InitContainer{{Name: "migrate"}}}},
+		{
+			name:   "invalid name",
+			spec:   AppSpec{InitContainers: []InitContainer{{Name: "Migrate"}}},
+			fields: []string{"spec.initContainers[0].name"},
+		},
+		{
+			name: "named after a sidecar",
+			spec: AppSpec{
+				Sidecars:       []Sidecar{{Name: "proxy", Image: "envoy"}},
+				InitContainers: []InitContainer{{Name: "proxy"}},
+			},
+			fields: []string{"spec.initContainers[0].name"},
+		},
+		{
+			name:   "invalid quantities",
+			spec:   AppSpec{InitContainers: []InitContainer{{Name: "migrate", Cpu: "fast", Memory: "big"}}},
+			fields: []string{"spec.initContainers[0].cpu", "spec.initContainers[0].memory"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.spec)
+			if got := errorFields(app.validateInitContainers(field.NewPath("spec", "initContainers"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateVolumes(t *testing.T) {
+	claim := &ClaimVolume{Size: resource.MustParse("1Gi")}
+	shared := &ClaimVolume{Size: resource.MustParse("1Gi"), AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}}
+
+	tests := []struct {
+		name   string
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "valid volume", spec: AppSpec{Volumes: []Volume{{Name: "cache", MountPath: "/cache", EmptyDir: &EmptyDirVolume{}}}}},
+		{
+			name:   "reserved name",
+			spec:   AppSpec{Volumes: []Volume{{Name: "config", MountPath: "/data", EmptyDir: &EmptyDirVolume{}}}},
+			fields: []string{"spec.volumes[0].name"},
+		},
+		{
+			name: "duplicate name and mount path",
+			spec: AppSpec{Volumes: []Volume{
+				{Name: "cache", MountPath: "/cache", EmptyDir: &EmptyDirVolume{}},
+				{Name: "cache", MountPath: "/cache", EmptyDir: &EmptyDirVolume{}},
+			}},
+			fields: []string{"spec.volumes[1].name", "spec.volumes[1].mountPath"},
+		},
+		{
+			name:   "mounted over the config",
+			spec:   AppSpec{Config: map[string]string{"a": "b"}, ConfigMountPath: "/config", Volumes: []Volume{{Name: "cache", MountPath: "/config", EmptyDir: &EmptyDirVolume{}}}},
+			fields: []string{"spec.volumes[0].mountPath"},
+		},
+		{
+			name:   "relative mount path",
+			spec:   AppSpec{Volumes: []Volume{{Name: "cache", MountPath: "cache", EmptyDir: &EmptyDirVolume{}}}},
+			fields: []string{"spec.volumes[0].mountPath"},
+		},
+		{
+			name:   "no source",
+			spec:   AppSpec{Volumes: []Volume{{Name: "cache", MountPath: "/cache"}}},
+			fields: []string{"spec.volumes[0]"},
+		},
+		{
+			name:   "two sources",
+			spec:   AppSpec{Volumes: []Volume{{Name: "cache", MountPath: "/cache", EmptyDir: &EmptyDirVolume{}, ExistingClaim: "cache"}}},
+			fields: []string{"spec.volumes[0]"},
+		},
+		{
+			name: "ReadWriteOnce claim on a single instance",
+			spec: AppSpec{Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim}}},
+		},
+		{
+			name:   "ReadWriteOnce claim on several instances",
+			spec:   AppSpec{Instances: int32Ptr(2), Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim}}},
+			fields: []string{"spec.volumes[0].claim.accessModes"},
+		},
+		{
+			name:   "ReadWriteOnce claim with a canary",
+			spec:   AppSpec{Canary: &Canary{Version: "v2"}, Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim}}},
+			fields: []string{"spec.volumes[0].claim.accessModes"},
+		},
+		{
+			name: "ReadWriteOnce claim on a StatefulSet",
+			spec: AppSpec{WorkloadKind: WorkloadStatefulSet, Instances: int32Ptr(3), Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim}}},
+		},
+		{
+			name: "ReadWriteMany claim on several instances",
+			spec: AppSpec{Autoscaling: &Autoscaling{MaxInstances: 4}, Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: shared}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.spec)
+			if got := errorFields(app.validateVolumes(field.NewPath("spec", "volumes"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateProbes(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "no probes"},
+		{
+			name: "http probe falls back to the health check endpoint",
+			spec: AppSpec{HealthCheckType: HealthCheckHTTP, HealthCheckEndpoint: "/healthz", Probes: &Probes{Liveness: &Probe{}}},
+		},
+		{
+			name:   "http probe with relative path",
+			spec:   AppSpec{Probes: &Probes{Readiness: &Probe{Type: HealthCheckHTTP, Path: "ready"}}},
+			fields: []string{"spec.probes.readiness.path"},
+		},
+		{
+			name: "http probe with invalid header",
+			spec: AppSpec{Probes: &Probes{Readiness: &Probe{
+				Type:    HealthCheckHTTP,
+				Path:    "/ready",
+				Headers: []corev1.HTTPHeader{{Name: "x header", Value: "1"}},
+			}}},
+			fields: []string{"spec.probes.readiness.headers[0].name"},
+		},
+		{
+			name:   "exec probe without command",
+			spec:   AppSpec{Probes: &Probes{Startup: &Probe{Type: HealthCheckExec}}},
+			fields: []string{"spec.probes.startup.command"},
+		},
+		{
+			name: "exec probe falls back to the health check command",
+			spec: AppSpec{HealthCheckCommand: []string{"true"}, Probes: &Probes{Startup: &Probe{Type: HealthCheckExec}}},
+		},
+		{
+			name: "success threshold above 1",
+			spec: AppSpec{Probes: &Probes{
+				Readiness: &Probe{SuccessThreshold: int32Ptr(2)},
+				Liveness:  &Probe{SuccessThreshold: int32Ptr(2)},
+			}},
+			fields: []string{"spec.probes.liveness.successThreshold"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.spec)
+			if got := errorFields(app.validateProbes(field.NewPath("spec", "probes"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateImmutableFields(t *testing.T) {
+	claim := func(size string) *ClaimVolume { return &ClaimVolume{Size: resource.MustParse(size)} }
+
+	tests := []struct {
+		name   string
+		old    AppSpec
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "unchanged"},
+		{name: "workload kind defaulted", old: AppSpec{WorkloadKind: WorkloadDeployment}},
+		{
+			name:   "workload kind changed",
+			spec:   AppSpec{WorkloadKind: WorkloadStatefulSet},
+			fields: []string{"spec.workloadKind"},
+		},
+		{
+			name:   "claim resized",
+			old:    AppSpec{Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim("1Gi")}}},
+			spec:   AppSpec{Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim("2Gi")}}},
+			fields: []string{"spec.volumes[0].claim"},
+		},
+		{
+			name: "claim added to a Deployment",
+			spec: AppSpec{Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim("1Gi")}}},
+		},
+		{
+			name:   "claim added to a StatefulSet",
+			old:    AppSpec{WorkloadKind: WorkloadStatefulSet},
+			spec:   AppSpec{WorkloadKind: WorkloadStatefulSet, Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim("1Gi")}}},
+			fields: []string{"spec.volumes[0].claim"},
+		},
+		{
+			name:   "claim removed from a StatefulSet",
+			old:    AppSpec{WorkloadKind: WorkloadStatefulSet, Volumes: []Volume{{Name: "data", MountPath: "/data", Claim: claim("1Gi")}}},
+			spec:   AppSpec{WorkloadKind: WorkloadStatefulSet},
+			fields: []string{"spec.volumes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(newApp(tt.spec).validateImmutableFields(newApp(tt.old))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources *Resources
+		fields    []string
+	}{
+		{name: "profile", resources: &Resources{Profile: ResourceProfileMedium}},
+		{
+			name: "limit above request",
+			resources: &Resources{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			},
+		},
+		{
+			name: "limit below request",
+			resources: &Resources{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			fields: []string{"spec.resources.limits[memory]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(AppSpec{Resources: tt.resources})
+			if got := errorFields(app.validateResources(field.NewPath("spec", "resources"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateNetworkPeers(t *testing.T) {
+	tests := []struct {
+		name   string
+		peers  []NetworkPeer
+		fields []string
+	}{
+		{name: "app and cidr peers", peers: []NetworkPeer{{App: "api", Namespace: "shared"}, {Cidr: "10.0.0.0/8"}}},
+		{
+			name:   "neither app nor cidr",
+			peers:  []NetworkPeer{{}},
+			fields: []string{"spec.ingressFrom[0]"},
+		},
+		{
+			name:   "both app and cidr",
+			peers:  []NetworkPeer{{App: "api", Cidr: "10.0.0.0/8"}},
+			fields: []string{"spec.ingressFrom[0]"},
+		},
+		{
+			name:   "invalid cidr with a namespace",
+			peers:  []NetworkPeer{{Cidr: "10.0.0.0", Namespace: "shared"}},
+			fields: []string{"spec.ingressFrom[0].cidr", "spec.ingressFrom[0].namespace"},
+		},
+		{
+			name:   "invalid app and namespace names",
+			peers:  []NetworkPeer{{App: "API", Namespace: "Shared"}},
+			fields: []string{"spec.ingressFrom[0].app", "spec.ingressFrom[0].namespace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(validateNetworkPeers(tt.peers, field.NewPath("spec", "ingressFrom"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   AppSpec
+		fields []string
+	}{
+		{name: "no access rules"},
+		{
+			name: "valid rules",
+			spec: AppSpec{Access: &Access{
+				ServiceAccounts: []string{"api", "shared/worker"},
+				Namespaces:      []string{"shared"},
+				Methods:         []string{"GET"},
+				Paths:           []string{"/api/*", "*/health"},
+			}},
+		},
+		{
+			name:   "too many service account parts",
+			spec:   AppSpec{Access: &Access{ServiceAccounts: []string{"a/b/c"}}},
+			fields: []string{"spec.access.serviceAccounts[0]"},
+		},
+		{
+			name:   "invalid service account and namespace names",
+			spec:   AppSpec{Access: &Access{ServiceAccounts: []string{"Shared/api"}, Namespaces: []string{"Shared"}}},
+			fields: []string{"spec.access.serviceAccounts[0]", "spec.access.namespaces[0]"},
+		},
+		{
+			name:   "lower case method and relative path",
+			spec:   AppSpec{Access: &Access{Methods: []string{"get"}, Paths: []string{"api"}}},
+			fields: []string{"spec.access.methods[0]", "spec.access.paths[0]"},
+		},
+		{
+			name:   "mTLS disabled",
+			spec:   AppSpec{DisableMtls: boolPtr(true), Access: &Access{}},
+			fields: []string{"spec.access"},
+		},
+		{
+			name:   "sidecar disabled",
+			spec:   AppSpec{DisableSidecar: boolPtr(true), Access: &Access{}},
+			fields: []string{"spec.access"},
+		},
+		{
+			name: "mTLS and sidecar explicitly enabled",
+			spec: AppSpec{DisableMtls: boolPtr(false), DisableSidecar: boolPtr(false), Access: &Access{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newApp(tt.spec)
+			if got := errorFields(app.validateAccess(field.NewPath("spec", "access"))); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("errors on %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestValidateQuantity(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{value: "", valid: true},
+		{value: "250m", valid: true},
+		{value: "1Gi", valid: true},
+		{value: "1GB"},
+		{value: "fast"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if errs := validateQuantity(tt.value, field.NewPath("spec", "cpu")); (len(errs) == 0) != tt.valid {
+				t.Errorf("errors = %v, want valid %v", errs, tt.valid)
+			}
+		})
+	}
+}
+
+// Updates that leave the spec alone, such as finalizer changes, pass even when the stored spec no longer validates
+func TestValidateUpdate(t *testing.T) {
+	invalid := newApp(AppSpec{Cpu: "lots"})
+
+	relabelled := invalid.DeepCopy()
+	relabelled.Labels = map[string]string{"team": "payments"}
+	relabelled.Finalizers = []string{"kappa.io/finalizer"}
+	if err := relabelled.ValidateUpdate(invalid); err != nil {
+		t.Errorf("metadata update rejected: %v", err)
+	}
+
+	changed := invalid.DeepCopy()
+	changed.Spec.Memory = "1Gi"
+	if err := changed.ValidateUpdate(invalid); err == nil {
+		t.Error("spec update with an invalid cpu accepted")
+	}
+
+	now := metav1.Now()
+	changed.DeletionTimestamp = &now
+	if err := changed.ValidateUpdate(invalid); err != nil {
+		t.Errorf("update of an App being deleted rejected: %v", err)
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,18 +30,171 @@ type EnvironmentSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Foo is an example field of Environment. Edit environment_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	//+kubebuilder:validation:Optional
+	// Labels to add to the namespace
+	Labels map[string]string `json:"labels,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Annotations to add to the namespace
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	// Enable Istio sidecar injection for the namespace, defaults to true
+	IstioInjection *bool `json:"istioInjection,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Hard limits of the namespace ResourceQuota, defaults to 4 cpu and 8Gi memory. The Budget's
+	// cpu, memory and instances replace the matching limits.
+	Quota v1.ResourceList `json:"quota,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Default container requests of the namespace LimitRange, defaults to 100m cpu and 128Mi memory
+	DefaultRequest v1.ResourceList `json:"defaultRequest,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Default container limits of the namespace LimitRange, defaults to 500m cpu and 512Mi memory
+	DefaultLimit v1.ResourceList `json:"defaultLimit,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Teams granted access to the namespace
+	Teams []TeamAccess `json:"teams,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Deny all traffic to and from the namespace's pods unless their App allows it, defaults to false.
+	// DNS and the Istio control plane stay reachable.
+	DefaultDenyNetwork *bool `json:"defaultDenyNetwork,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Cap on what the Apps in the namespace may request in total
+	Budget *Budget `json:"budget,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Defaults for every App in the namespace, overridden by the App's own settings
+	AppDefaults *AppDefaults `json:"appDefaults,omitempty"`
+}
+
+// Budget caps the requests of every instance of every App in an Environment, at the largest
+// scale each App may reach, counting Istio proxies and the pods of canaries and blue/green
+// previews. An App that doesn't fit in what the other Apps leave is held at the instances that
+// do, recorded in its status.allowedInstances, and reports why in its WithinBudget condition.
+// The namespace ResourceQuota enforces the same totals.
+type Budget struct {
+	//+kubebuilder:validation:Optional
+	// Total cpu requests
+	Cpu *resource.Quantity `json:"cpu,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Total memory requests
+	Memory *resource.Quantity `json:"memory,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Minimum=0
+	// Total instances
+	Instances *int32 `json:"instances,omitempty"`
+}
+
+// AppDefaults are merged under the spec of every App in an Environment's namespace. Fields the
+// App sets win; maps and lists are merged, with the App's entries winning on conflicts.
+type AppDefaults struct {
+	//+kubebuilder:validation:Optional
+	// Memory Request/Limit
+	Memory string `json:"memory,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Cpu Request/Limit
+	Cpu string `json:"cpu,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Resources block, used when the App has none
+	Resources *Resources `json:"resources,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Node Selector
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Labels to add to all resources
+	Labels map[string]string `json:"labels,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Annotations to add to all resources
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Environment Variables
+	Env []v1.EnvVar `json:"env,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Secrets to mount as environment variables
+	Secrets []string `json:"secrets,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Image Pull Secrets
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Run every container with a read-only root filesystem
+	ReadOnlyRootFilesystem *bool `json:"readOnlyRootFilesystem,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Disable Istio MTLS
+	DisableMtls *bool `json:"disableMtls,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Disable istio sidecar
+	DisableSidecar *bool `json:"disableSidecar,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Expose routes through the ingress gateway
+	Public *bool `json:"public,omitempty"`
+}
+
+// TeamAccess binds a team's users and groups to a ClusterRole in the namespace
+type TeamAccess struct {
+	//+kubebuilder:validation:Required
+	// Name of the team, used to name the RoleBinding
+	Name string `json:"name"`
+
+	//+kubebuilder:validation:Optional
+	// +kubebuilder:default:="edit"
+	// ClusterRole to bind, defaults to "edit"
+	Role string `json:"role,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Groups that are members of the team
+	Groups []string `json:"groups,omitempty"`
+
+	//+kubebuilder:validation:Optional
+	// Users that are members of the team
+	Users []string `json:"users,omitempty"`
 }
 
 // EnvironmentStatus defines the observed state of Environment
 type EnvironmentStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Namespace provisioned for the environment
+	Namespace string `json:"namespace,omitempty"`
+
+	// ResourceQuota provisioned in the namespace
+	ResourceQuota string `json:"resourceQuota,omitempty"`
+
+	// LimitRange provisioned in the namespace
+	LimitRange string `json:"limitRange,omitempty"`
+
+	// RoleBindings provisioned in the namespace
+	RoleBindings []string `json:"roleBindings,omitempty"`
+
+	// Default-deny NetworkPolicy provisioned in the namespace
+	NetworkPolicy string `json:"networkPolicy,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.status.namespace`
 
 // Environment is the Schema for the environments API
 type Environment struct {
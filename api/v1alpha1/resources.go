@@ -0,0 +1,95 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Resource profiles an App can pick instead of setting Cpu and Memory
+const (
+	ResourceProfileSmall  = "small"
+	ResourceProfileMedium = "medium"
+	ResourceProfileLarge  = "large"
+)
+
+// resourceProfiles holds the requests and cpu limits of each profile. Memory is limited to the
+// request. The cpu limit is explicit, since a namespace's default limit could be below the request.
+var resourceProfiles = map[string]struct{ cpu, cpuLimit, memory string }{
+	ResourceProfileSmall:  {cpu: "100m", cpuLimit: "200m", memory: "128Mi"},
+	ResourceProfileMedium: {cpu: "250m", cpuLimit: "500m", memory: "512Mi"},
+	ResourceProfileLarge:  {cpu: "1", cpuLimit: "2", memory: "2Gi"},
+}
+
+// ResourceRequirements returns the application container's requests and limits, from the profile
+// or Cpu and Memory (200m and 256Mi when unset), overridden per resource by Resources. A resource
+// Resources gives only a request or only a limit uses the same value for the other.
+func (s *AppSpec) ResourceRequirements() (v1.ResourceRequirements, error) {
+	requirements := v1.ResourceRequirements{
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}
+
+	var profileName string
+	if s.Resources != nil {
+		profileName = s.Resources.Profile
+	}
+	if profile, ok := resourceProfiles[profileName]; ok {
+		requirements.Requests[v1.ResourceCPU] = resource.MustParse(profile.cpu)
+		requirements.Limits[v1.ResourceCPU] = resource.MustParse(profile.cpuLimit)
+		requirements.Requests[v1.ResourceMemory] = resource.MustParse(profile.memory)
+		requirements.Limits[v1.ResourceMemory] = resource.MustParse(profile.memory)
+	} else {
+		cpu, memory := s.Cpu, s.Memory
+		if cpu == "" {
+			cpu = "200m"
+		}
+		if memory == "" {
+			memory = "256Mi"
+		}
+		cpuQuantity, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return v1.ResourceRequirements{}, fmt.Errorf("invalid cpu %q: %w", cpu, err)
+		}
+		memoryQuantity, err := resource.ParseQuantity(memory)
+		if err != nil {
+			return v1.ResourceRequirements{}, fmt.Errorf("invalid memory %q: %w", memory, err)
+		}
+		requirements.Requests[v1.ResourceCPU] = cpuQuantity
+		requirements.Limits[v1.ResourceCPU] = cpuQuantity.DeepCopy()
+		requirements.Requests[v1.ResourceMemory] = memoryQuantity
+		requirements.Limits[v1.ResourceMemory] = memoryQuantity.DeepCopy()
+	}
+
+	if s.Resources != nil {
+		for name, q := range s.Resources.Requests {
+			requirements.Requests[name] = q.DeepCopy()
+			if _, ok := s.Resources.Limits[name]; !ok {
+				requirements.Limits[name] = q.DeepCopy()
+			}
+		}
+		for name, q := range s.Resources.Limits {
+			requirements.Limits[name] = q.DeepCopy()
+			if _, ok := s.Resources.Requests[name]; !ok {
+				requirements.Requests[name] = q.DeepCopy()
+			}
+		}
+	}
+	return requirements, nil
+}
@@ -0,0 +1,126 @@
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+)
+
+func TestResourceRequirements(t *testing.T) {
+	list := func(cpu, memory string) v1.ResourceList {
+		return v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+	}
+
+	tests := []struct {
+		name     string
+		spec     AppSpec
+		requests v1.ResourceList
+		limits   v1.ResourceList
+		invalid  bool
+	}{
+		{
+			name:     "defaults",
+			requests: list("200m", "256Mi"),
+			limits:   list("200m", "256Mi"),
+		},
+		{
+			name:     "cpu and memory",
+			spec:     AppSpec{Cpu: "500m", Memory: "1Gi"},
+			requests: list("500m", "1Gi"),
+			limits:   list("500m", "1Gi"),
+		},
+		{
+			name:    "invalid cpu",
+			spec:    AppSpec{Cpu: "fast"},
+			invalid: true,
+		},
+		{
+			name:     "invalid memory under a profile is unused",
+			spec:     AppSpec{Memory: "lots", Resources: &Resources{Profile: ResourceProfileSmall}},
+			requests: list("100m", "128Mi"),
+			limits:   list("200m", "128Mi"),
+		},
+		{
+			name:     "small profile",
+			spec:     AppSpec{Resources: &Resources{Profile: ResourceProfileSmall}},
+			requests: list("100m", "128Mi"),
+			limits:   list("200m", "128Mi"),
+		},
+		{
+			name:     "large profile replaces cpu and memory",
+			spec:     AppSpec{Cpu: "500m", Memory: "1Gi", Resources: &Resources{Profile: ResourceProfileLarge}},
+			requests: list("1", "2Gi"),
+			limits:   list("2", "2Gi"),
+		},
+		{
+			name: "request and limit on top of a profile",
+			spec: AppSpec{Resources: &Resources{
+				Profile:  ResourceProfileMedium,
+				Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+				Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")},
+			}},
+			requests: list("250m", "256Mi"),
+			limits:   list("500m", "1Gi"),
+		},
+		{
+			name:     "request only sets the limit too",
+			spec:     AppSpec{Resources: &Resources{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}}},
+			requests: list("1", "256Mi"),
+			limits:   list("1", "256Mi"),
+		},
+		{
+			name:     "limit only sets the request too",
+			spec:     AppSpec{Resources: &Resources{Profile: ResourceProfileSmall, Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("50m")}}},
+			requests: list("50m", "128Mi"),
+			limits:   list("50m", "128Mi"),
+		},
+		{
+			name: "other resources",
+			spec: AppSpec{Resources: &Resources{Limits: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("1Gi")}}},
+			requests: v1.ResourceList{
+				v1.ResourceCPU:              resource.MustParse("200m"),
+				v1.ResourceMemory:           resource.MustParse("256Mi"),
+				v1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+			},
+			limits: v1.ResourceList{
+				v1.ResourceCPU:              resource.MustParse("200m"),
+				v1.ResourceMemory:           resource.MustParse("256Mi"),
+				v1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requirements, err := tt.spec.ResourceRequirements()
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("requirements %v for an invalid spec, want an error", requirements)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalResourceLists(requirements.Requests, tt.requests) {
+				t.Errorf("requests = %v, want %v", requirements.Requests, tt.requests)
+			}
+			if !equalResourceLists(requirements.Limits, tt.limits) {
+				t.Errorf("limits = %v, want %v", requirements.Limits, tt.limits)
+			}
+		})
+	}
+}
+
+// equalResourceLists compares quantities by value, regardless of how they were written
+func equalResourceLists(a, b v1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, q := range a {
+		if other, ok := b[name]; !ok || q.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -21,13 +21,21 @@ import (
 	"github.com/go-logr/logr"
 	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
 	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	security "istio.io/client-go/pkg/apis/security/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // AppReconciler reconciles a App object
@@ -35,11 +43,38 @@ type AppReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// Domain is appended to the app name to build the default public hostname
+	Domain string
+	// Gateway is the Istio ingress Gateway public Apps are bound to, as <namespace>/<name>
+	Gateway string
+	// GatewaySelector selects the pods of the Gateway's ingress deployment, which NetworkPolicies
+	// let reach public Apps
+	GatewaySelector map[string]string
+	// GatewayServiceAccount is the ServiceAccount of the Gateway's ingress deployment, as
+	// <namespace>/<name>, which AuthorizationPolicies let call public Apps
+	GatewayServiceAccount string
+	// TrustDomain is the mesh's trust domain, which workload principals are issued under
+	TrustDomain string
+	// CleanupHooks remove what an App created but cannot own when it is deleted
+	CleanupHooks []CleanupHook
 }
 
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=apps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=apps/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=apps/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kapp.kappa.io,resources=environments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.istio.io,resources=virtualservices;destinationrules,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,31 +96,62 @@ func (r *AppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	res, err := r.reconcileServiceAccount(ctx, req, app)
-	if err != nil {
-		return res, err
+	if !app.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, app)
 	}
 
-	res, err = r.reconcileDeployment(ctx, req, app)
-	if err != nil {
-		return res, err
+	if err := r.ensureFinalizer(ctx, app); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	res, err = r.reconcileService(ctx, req, app)
-	if err != nil {
-		return res, err
+	original := app.Status.DeepCopy()
+	if err := r.applyEnvironmentDefaults(ctx, app); err != nil {
+		return ctrl.Result{}, err
 	}
-	res, err = r.reconcileVirtualService(ctx, req, app)
-	if err != nil {
-		return res, err
+
+	// Nothing is reconciled from an invalid effective spec. There is no point retrying it either,
+	// as changes to the App or its Environment requeue it.
+	if err := app.ValidateSpec(); err != nil {
+		r.setInvalidSpec(app, err)
+		return ctrl.Result{}, r.updateStatus(ctx, app, original)
 	}
 
-	res, err = r.reconcileDestinationRule(ctx, req, app)
-	if err != nil {
-		return res, err
+	// Past this point the workload is held at status.allowedInstances
+	if err := r.reconcileBudget(ctx, app); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	steps := []reconcileStep{
+		{condition: kappv1alpha1.ConditionServiceAccountReconciled, generator: serviceAccountGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionConfigMapReconciled, generator: configMapGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionVolumeClaimsReconciled, reconcile: r.reconcileVolumeClaims},
+		{condition: kappv1alpha1.ConditionDeploymentReconciled, generator: deploymentGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionStatefulSetReconciled, generator: statefulSetGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionJobReconciled, generator: jobGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionCronJobReconciled, generator: cronJobGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionBlueGreenReconciled, reconcile: r.reconcileBlueGreen},
+		{condition: kappv1alpha1.ConditionAutoscalerReconciled, generator: autoscalerGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionCanaryReconciled, reconcile: r.reconcileCanary},
+		{condition: kappv1alpha1.ConditionDisruptionBudgetReconciled, generator: disruptionBudgetGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionServiceReconciled, generator: serviceGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionHeadlessServiceReconciled, generator: headlessServiceGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionVirtualServiceReconciled, generator: virtualServiceGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionDestinationRuleReconciled, generator: destinationRuleGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionAuthorizationPolicyReconciled, generator: authorizationPolicyGenerator{r: r}},
+		{condition: kappv1alpha1.ConditionNetworkPolicyReconciled, reconcile: r.reconcileNetworkPolicy},
+	}
+
+	res, err := r.runSteps(ctx, req, app, steps)
+	r.setAppConditions(app, err)
+	if app.Status.AllowedInstances != nil && (res.RequeueAfter == 0 || res.RequeueAfter > budgetRecheckInterval) {
+		res.RequeueAfter = budgetRecheckInterval
+	}
+
+	if statusErr := r.updateStatus(ctx, app, original); statusErr != nil && err == nil {
+		return ctrl.Result{}, statusErr
 	}
 
-	return ctrl.Result{}, nil
+	return res, err
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -93,11 +159,21 @@ func (r *AppReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kappv1alpha1.App{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Owns(&batchv1beta1.CronJob{}).
+		Owns(&autoscalingv2beta2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1beta1.PodDisruptionBudget{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ServiceAccount{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
 		Owns(&istio.VirtualService{}).
 		Owns(&istio.DestinationRule{}).
+		Owns(&security.AuthorizationPolicy{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&source.Kind{Type: &kappv1alpha1.Environment{}}, handler.EnqueueRequestsFromMapFunc(r.appsForEnvironment)).
 		Complete(r)
 }
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// fieldManager identifies kappa as the owner of the fields it applies
+const fieldManager = "kappa"
+
+// replicasFieldManager keeps owning a workload's replica count once kappa hands it to the
+// HorizontalPodAutoscaler, see handOverReplicas
+const replicasFieldManager = "kappa-replicas"
+
+// apply server-side applies obj as a resource controlled by the App. Only the fields set on obj
+// are managed by kappa, so fields set by other actors such as the HorizontalPodAutoscaler, Istio
+// or admission webhooks are left alone, and fields kappa stops setting are released.
+// obj is updated with the object returned by the API server.
+func (r *AppReconciler) apply(ctx context.Context, app *kappv1alpha1.App, obj client.Object) error {
+	if err := controllerutil.SetControllerReference(app, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	// Apply requests must carry their type and may not carry managed fields
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+
+	return r.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// handOverReplicas keeps a workload's replica count when kappa stops applying it, such as when
+// autoscaling is enabled. Server-side apply removes a field its only owner stops setting, which
+// would reset the workload to a single replica, so the live count is first applied by a second
+// field manager that keeps owning it after kappa lets go.
+func (r *AppReconciler) handOverReplicas(ctx context.Context, desired, existing client.Object) error {
+	var desiredReplicas, existingReplicas *int32
+	switch d := desired.(type) {
+	case *appsv1.Deployment:
+		desiredReplicas, existingReplicas = d.Spec.Replicas, existing.(*appsv1.Deployment).Spec.Replicas
+	case *appsv1.StatefulSet:
+		desiredReplicas, existingReplicas = d.Spec.Replicas, existing.(*appsv1.StatefulSet).Spec.Replicas
+	default:
+		return nil
+	}
+	if desiredReplicas != nil || existingReplicas == nil || !managesField(existing, fieldManager, "spec", "replicas") {
+		return nil
+	}
+
+	gvk, err := apiutil.GVKForObject(desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+	replicas := &unstructured.Unstructured{}
+	replicas.SetGroupVersionKind(gvk)
+	replicas.SetName(existing.GetName())
+	replicas.SetNamespace(existing.GetNamespace())
+	if err := unstructured.SetNestedField(replicas.Object, int64(*existingReplicas), "spec", "replicas"); err != nil {
+		return err
+	}
+
+	r.Log.Info("Handing over replicas", "Name", existing.GetName(), "Namespace", existing.GetNamespace(), "Replicas", *existingReplicas)
+	return r.Patch(ctx, replicas, client.Apply, client.FieldOwner(replicasFieldManager))
+}
+
+// managesField reports whether manager applied the field at path on obj
+func managesField(obj client.Object, manager string, path ...string) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		found := true
+		for _, name := range path {
+			next, ok := fields["f:"+name].(map[string]interface{})
+			if !ok {
+				found = false
+				break
+			}
+			fields = next
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// appliedPatch is a server-side apply request seen by applyClient
+type appliedPatch struct {
+	manager string
+	obj     client.Object
+}
+
+// applyClient stands in for server-side apply, which the fake client does not support. Typed
+// objects are created or replaced, keeping their status as the API server would; every apply
+// is recorded with its field manager.
+type applyClient struct {
+	client.Client
+	applied []appliedPatch
+}
+
+func newApplyClient(scheme *runtime.Scheme, objs ...client.Object) *applyClient {
+	return &applyClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	c.applied = append(c.applied, appliedPatch{manager: options.FieldManager, obj: obj.DeepCopyObject().(client.Object)})
+
+	// Unstructured applies only set individual fields, which is all tests look at
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		return nil
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if errors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	if err != nil {
+		return err
+	}
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	if status, ok := current["status"]; ok {
+		desired["status"] = status
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desired, obj); err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+// lastApplied returns the last object applied with the given name, or nil
+func (c *applyClient) lastApplied(name string) *appliedPatch {
+	for i := len(c.applied) - 1; i >= 0; i-- {
+		if c.applied[i].obj.GetName() == name {
+			return &c.applied[i]
+		}
+	}
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "uid"}}
+	c := newApplyClient(scheme)
+	r := &AppReconciler{Client: c, Scheme: scheme}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:            "web",
+		Namespace:       "team",
+		ResourceVersion: "7",
+		ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+	}}
+	if err := r.apply(ctx, app, sa); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := c.lastApplied("web")
+	if patch == nil || patch.manager != fieldManager {
+		t.Fatalf("applied %+v, want an apply by %q", patch, fieldManager)
+	}
+	sent := patch.obj
+	if gvk := sent.GetObjectKind().GroupVersionKind(); gvk.Kind != "ServiceAccount" || gvk.Version != "v1" {
+		t.Errorf("applied with type %v, want v1 ServiceAccount", gvk)
+	}
+	if sent.GetManagedFields() != nil || sent.GetResourceVersion() != "" {
+		t.Errorf("applied with managed fields %v and resource version %q, want neither", sent.GetManagedFields(), sent.GetResourceVersion())
+	}
+	if !metav1.IsControlledBy(sent, app) {
+		t.Errorf("applied with owners %v, want the App as controller", sent.GetOwnerReferences())
+	}
+}
+
+// appliedReplicas returns a managed fields entry for manager owning spec.replicas
+func appliedReplicas(manager string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:   manager,
+		Operation: metav1.ManagedFieldsOperationApply,
+		FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+	}
+}
+
+func TestHandOverReplicas(t *testing.T) {
+	deployment := func(replicas *int32, managers ...string) *appsv1.Deployment {
+		dep := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+		}
+		for _, manager := range managers {
+			dep.ManagedFields = append(dep.ManagedFields, appliedReplicas(manager))
+		}
+		return dep
+	}
+
+	tests := []struct {
+		name     string
+		desired  client.Object
+		existing client.Object
+		handover bool
+	}{
+		{
+			name:     "kappa stops applying the replicas it owns",
+			desired:  deployment(nil),
+			existing: deployment(pointer.Int32Ptr(5), fieldManager),
+			handover: true,
+		},
+		{
+			name:     "kappa keeps applying replicas",
+			desired:  deployment(pointer.Int32Ptr(2)),
+			existing: deployment(pointer.Int32Ptr(5), fieldManager),
+		},
+		{
+			name:     "already handed over",
+			desired:  deployment(nil),
+			existing: deployment(pointer.Int32Ptr(5), replicasFieldManager),
+		},
+		{
+			name:     "kinds without replicas",
+			desired:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}},
+			existing: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			c := newApplyClient(scheme)
+			r := &AppReconciler{Client: c, Log: ctrl.Log, Scheme: scheme}
+			if err := r.handOverReplicas(context.Background(), tt.desired, tt.existing); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.handover {
+				if len(c.applied) != 0 {
+					t.Errorf("applied %+v, want nothing", c.applied)
+				}
+				return
+			}
+			if len(c.applied) != 1 || c.applied[0].manager != replicasFieldManager {
+				t.Fatalf("applied %+v, want one apply by %s", c.applied, replicasFieldManager)
+			}
+			replicas, _, _ := unstructured.NestedInt64(c.applied[0].obj.(*unstructured.Unstructured).Object, "spec", "replicas")
+			if replicas != 5 {
+				t.Errorf("handed over %d replicas, want the live 5", replicas)
+			}
+		})
+	}
+}
+
+func TestManagesField(t *testing.T) {
+	obj := &appsv1.Deployment{}
+	obj.ManagedFields = []metav1.ManagedFieldsEntry{
+		appliedReplicas(fieldManager),
+		{
+			Manager:   "kube-controller-manager",
+			Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:paused":{}}}`)},
+		},
+	}
+
+	if !managesField(obj, fieldManager, "spec", "replicas") {
+		t.Error("kappa's applied replicas not found")
+	}
+	if managesField(obj, fieldManager, "spec", "paused") {
+		t.Error("kappa reported as managing a field it never applied")
+	}
+	if managesField(obj, "kube-controller-manager", "spec", "paused") {
+		t.Error("an update reported as an apply")
+	}
+}
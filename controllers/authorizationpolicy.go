@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	security "istio.io/client-go/pkg/apis/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// authorizationPolicyGenerator produces the AuthorizationPolicy restricting who may call the App
+type authorizationPolicyGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g authorizationPolicyGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &security.AuthorizationPolicy{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g authorizationPolicyGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if app.Spec.Access == nil || !receivesTraffic(app) {
+		return nil, nil
+	}
+	return g.r.authorizationPolicy(app), nil
+}
+
+func (r *AppReconciler) authorizationPolicy(app *kappv1alpha1.App) *security.AuthorizationPolicy {
+	access := app.Spec.Access
+
+	var from []*v1beta1.Rule_From
+	if len(access.ServiceAccounts) > 0 {
+		principals := make([]string, 0, len(access.ServiceAccounts))
+		for _, sa := range access.ServiceAccounts {
+			principals = append(principals, r.servicePrincipal(app.Namespace, sa))
+		}
+		from = append(from, &v1beta1.Rule_From{Source: &v1beta1.Source{Principals: principals}})
+	}
+	if len(access.Namespaces) > 0 {
+		from = append(from, &v1beta1.Rule_From{Source: &v1beta1.Source{Namespaces: access.Namespaces}})
+	}
+	// An empty from already allows every caller, the gateway included
+	if len(from) > 0 && isPublic(app) && r.GatewayServiceAccount != "" {
+		from = append(from, &v1beta1.Rule_From{Source: &v1beta1.Source{
+			Principals: []string{r.servicePrincipal(app.Namespace, r.GatewayServiceAccount)},
+		}})
+	}
+
+	rule := &v1beta1.Rule{From: from}
+	if len(access.Methods) > 0 || len(access.Paths) > 0 {
+		rule.To = []*v1beta1.Rule_To{
+			{Operation: &v1beta1.Operation{Methods: access.Methods, Paths: access.Paths}},
+		}
+	}
+
+	return &security.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Labels:    appLabels(app),
+		},
+		Spec: v1beta1.AuthorizationPolicy{
+			Selector: &typev1beta1.WorkloadSelector{
+				MatchLabels: map[string]string{"app": app.Name},
+			},
+			Action: v1beta1.AuthorizationPolicy_ALLOW,
+			Rules:  []*v1beta1.Rule{rule},
+		},
+	}
+}
+
+// servicePrincipal returns the mesh identity of a ServiceAccount given as <namespace>/<name>,
+// or as <name> in namespace
+func (r *AppReconciler) servicePrincipal(namespace, serviceAccount string) string {
+	name := serviceAccount
+	if parts := strings.SplitN(serviceAccount, "/", 2); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
+	}
+	return fmt.Sprintf("%s/ns/%s/sa/%s", r.TrustDomain, namespace, name)
+}
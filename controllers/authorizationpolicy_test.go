@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"testing"
+)
+
+func TestServicePrincipal(t *testing.T) {
+	r := &AppReconciler{TrustDomain: "prod.example.com"}
+	for sa, want := range map[string]string{
+		"api":           "prod.example.com/ns/team/sa/api",
+		"shared/worker": "prod.example.com/ns/shared/sa/worker",
+	} {
+		if got := r.servicePrincipal("team", sa); got != want {
+			t.Errorf("servicePrincipal(team, %s) = %s, want %s", sa, got, want)
+		}
+	}
+}
+
+func TestAuthorizationPolicy(t *testing.T) {
+	r := &AppReconciler{GatewayServiceAccount: "istio-system/gateway", TrustDomain: "cluster.local"}
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"},
+		Spec: kappv1alpha1.AppSpec{
+			Public: pointer.BoolPtr(false),
+			Access: &kappv1alpha1.Access{
+				ServiceAccounts: []string{"api", "shared/worker"},
+				Namespaces:      []string{"batch"},
+				Methods:         []string{"GET"},
+				Paths:           []string{"/api/*"},
+			},
+		},
+	}
+
+	policy := r.authorizationPolicy(app)
+	if policy.Spec.Action != v1beta1.AuthorizationPolicy_ALLOW || policy.Spec.Selector.MatchLabels["app"] != "web" {
+		t.Errorf("action %v selecting %v, want ALLOW for the App's pods", policy.Spec.Action, policy.Spec.Selector)
+	}
+	if len(policy.Spec.Rules) != 1 {
+		t.Fatalf("rules = %v, want one", policy.Spec.Rules)
+	}
+	rule := policy.Spec.Rules[0]
+
+	var principals, namespaces []string
+	for _, from := range rule.From {
+		principals = append(principals, from.Source.Principals...)
+		namespaces = append(namespaces, from.Source.Namespaces...)
+	}
+	if want := []string{"cluster.local/ns/team/sa/api", "cluster.local/ns/shared/sa/worker"}; !reflect.DeepEqual(principals, want) {
+		t.Errorf("principals = %v, want %v", principals, want)
+	}
+	if want := []string{"batch"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("namespaces = %v, want %v", namespaces, want)
+	}
+	if len(rule.To) != 1 || !reflect.DeepEqual(rule.To[0].Operation.Methods, []string{"GET"}) || !reflect.DeepEqual(rule.To[0].Operation.Paths, []string{"/api/*"}) {
+		t.Errorf("to = %v, want GET on /api/*", rule.To)
+	}
+
+	// Public Apps stay reachable through the ingress gateway, and only through its identity
+	app.Spec.Public = pointer.BoolPtr(true)
+	rule = r.authorizationPolicy(app).Spec.Rules[0]
+	if len(rule.From) != 3 {
+		t.Fatalf("from = %v, want a source for the gateway", rule.From)
+	}
+	if gateway := rule.From[2].Source; !reflect.DeepEqual(gateway.Principals, []string{"cluster.local/ns/istio-system/sa/gateway"}) || gateway.Namespaces != nil {
+		t.Errorf("gateway source %v, want the gateway's service account", gateway)
+	}
+
+	// Without callers every source is allowed, so the gateway needs no rule of its own
+	app.Spec.Access = &kappv1alpha1.Access{Methods: []string{"GET"}}
+	rule = r.authorizationPolicy(app).Spec.Rules[0]
+	if rule.From != nil {
+		t.Errorf("from = %v, want any source", rule.From)
+	}
+}
+
+func TestAuthorizationPolicyGenerator(t *testing.T) {
+	g := authorizationPolicyGenerator{r: &AppReconciler{}}
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+
+	if obj, _ := g.desired(app); obj != nil {
+		t.Errorf("policy %v without access rules, want none", obj)
+	}
+	app.Spec.Access = &kappv1alpha1.Access{Namespaces: []string{"batch"}}
+	if obj, _ := g.desired(app); obj == nil {
+		t.Error("no policy for an App with access rules")
+	}
+	app.Spec.Worker = pointer.BoolPtr(true)
+	if obj, _ := g.desired(app); obj != nil {
+		t.Errorf("policy %v for a worker, want none", obj)
+	}
+}
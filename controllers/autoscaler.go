@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// autoscalerGenerator produces the HorizontalPodAutoscaler for Apps with autoscaling
+type autoscalerGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g autoscalerGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g autoscalerGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	// Remove a previously created autoscaler once autoscaling has been disabled, or while the
+	// budget leaves the App no instances at all
+	if !autoscalerActive(app) {
+		return nil, nil
+	}
+	return g.r.autoscaler(app), nil
+}
+
+// autoscalingEnabled reports whether the App's replica count is managed by a HorizontalPodAutoscaler
+func autoscalingEnabled(app *kappv1alpha1.App) bool {
+	return app.Spec.Autoscaling != nil
+}
+
+// autoscalerActive reports whether a HorizontalPodAutoscaler currently owns the App's replica count.
+// An App whose budget leaves it no instances is scaled to zero instead.
+func autoscalerActive(app *kappv1alpha1.App) bool {
+	return autoscalingEnabled(app) && budgetInstances(app, 1) > 0
+}
+
+// minInstances returns the lowest replica count the App should run with
+func minInstances(app *kappv1alpha1.App) int32 {
+	if autoscalingEnabled(app) {
+		if app.Spec.Autoscaling.MinInstances != nil {
+			return *app.Spec.Autoscaling.MinInstances
+		}
+		return 1
+	}
+	if app.Spec.Instances != nil {
+		return *app.Spec.Instances
+	}
+	return 1
+}
+
+// maxInstances returns the most instances the App may run, once autoscaled to its maximum
+func maxInstances(app *kappv1alpha1.App) int32 {
+	if batchWorkload(app) {
+		return 1
+	}
+	if autoscalingEnabled(app) {
+		return app.Spec.Autoscaling.MaxInstances
+	}
+	return minInstances(app)
+}
+
+func (r *AppReconciler) autoscaler(app *kappv1alpha1.App) *autoscalingv2beta2.HorizontalPodAutoscaler {
+	labels := appLabels(app)
+
+	minReplicas := budgetInstances(app, minInstances(app))
+	spec := app.Spec.Autoscaling
+
+	var metrics []autoscalingv2beta2.MetricSpec
+	if spec.TargetCpuUtilization != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, *spec.TargetCpuUtilization))
+	}
+	if spec.TargetMemoryUtilization != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceMemory, *spec.TargetMemoryUtilization))
+	}
+	for _, m := range spec.Metrics {
+		metrics = append(metrics, *m.DeepCopy())
+	}
+	// Match the API server default so an empty metric list doesn't read as drift
+	if len(metrics) == 0 {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, 80))
+	}
+
+	// Resource metrics average over the pods matching the target's selector. The rolling
+	// deployment selects every pod of the App, so a canary's pods are counted as well while one
+	// runs, whereas blue/green colors select only their own track.
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       workloadKind(app),
+				Name:       activeDeploymentName(app),
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: budgetInstances(app, spec.MaxInstances),
+			Metrics:     metrics,
+		},
+	}
+}
+
+func resourceMetric(name corev1.ResourceName, utilization int32) autoscalingv2beta2.MetricSpec {
+	return autoscalingv2beta2.MetricSpec{
+		Type: autoscalingv2beta2.ResourceMetricSourceType,
+		Resource: &autoscalingv2beta2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2beta2.MetricTarget{
+				Type:               autoscalingv2beta2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+const (
+	trackBlue  = "blue"
+	trackGreen = "green"
+)
+
+const defaultPreviewHeader = "x-kappa-preview"
+
+const defaultScaleDownDelay = 30 * time.Minute
+
+func (r *AppReconciler) reconcileBlueGreen(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
+	if !blueGreenEnabled(app) {
+		return r.removeBlueGreen(ctx, app)
+	}
+
+	// The first version deployed has nothing to be compared against, so it goes live directly
+	status := app.Status.BlueGreen
+	if status == nil {
+		status = &kappv1alpha1.BlueGreenStatus{ActiveColor: trackBlue, ActiveVersion: app.Spec.Version}
+		app.Status.BlueGreen = status
+	}
+
+	result := ctrl.Result{}
+	wasLive := status.Live
+
+	// Colors start at the scale of the deployment receiving live traffic, so the preview can take
+	// over the autoscaled load on promotion
+	scale := minInstances(app)
+	if autoscalerActive(app) {
+		liveName := app.Name
+		if status.Live {
+			liveName = colorName(app, status.ActiveColor)
+		}
+		current, err := r.liveReplicas(ctx, app, liveName)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if current > scale {
+			scale = current
+		}
+	}
+	scale = budgetInstances(app, scale)
+
+	if app.Spec.Version != status.ActiveVersion {
+		// A new version waits in the preview color until it is promoted
+		previewColor := otherColor(status.ActiveColor)
+		status.PreviewColor = previewColor
+		status.PreviewVersion = app.Spec.Version
+		status.ScaleDownTime = nil
+
+		previewReady, _, err := r.reconcileGenerated(ctx, app, colorGenerator{r: r, color: previewColor, version: status.PreviewVersion, replicas: &scale})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if blueGreenPromoted(app) && previewReady {
+			r.Log.Info("Promoting preview", "Name", app.Name, "Namespace", app.Namespace, "Color", previewColor, "Version", app.Spec.Version)
+			scaleDownTime := metav1.NewTime(time.Now().Add(scaleDownDelay(app)))
+			status.PreviewColor = status.ActiveColor
+			status.PreviewVersion = status.ActiveVersion
+			status.ScaleDownTime = &scaleDownTime
+			status.ActiveColor = previewColor
+			status.ActiveVersion = app.Spec.Version
+		}
+	}
+
+	// Leave the replica count to the HorizontalPodAutoscaler once it targets the active color,
+	// see handOverReplicas
+	activeReplicas := &scale
+	if autoscalerActive(app) && status.Live {
+		activeReplicas = nil
+	}
+	activeReady, _, err := r.reconcileGenerated(ctx, app, colorGenerator{r: r, color: status.ActiveColor, version: status.ActiveVersion, replicas: activeReplicas})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if activeReady && !status.Live {
+		r.Log.Info("Switching live traffic", "Name", app.Name, "Namespace", app.Namespace, "Color", status.ActiveColor)
+		status.Live = true
+	}
+
+	if app.Spec.Version == status.ActiveVersion && status.PreviewVersion != "" {
+		// Keep the previous version around for rollback until the scale down delay has passed
+		if status.ScaleDownTime == nil {
+			now := metav1.Now()
+			status.ScaleDownTime = &now
+		}
+		// The previous color keeps the scale it had while live, so rolling back is instant
+		previousReplicas, err := r.liveReplicas(ctx, app, colorName(app, status.PreviewColor))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if previousReplicas == 0 {
+			previousReplicas = scale
+		}
+		previous := colorGenerator{r: r, color: status.PreviewColor, version: status.PreviewVersion, replicas: &previousReplicas}
+		remaining := time.Until(status.ScaleDownTime.Time)
+		if remaining <= 0 {
+			previous.version = ""
+		}
+		if _, _, err := r.reconcileGenerated(ctx, app, previous); err != nil {
+			return ctrl.Result{}, err
+		}
+		if remaining > 0 {
+			result.RequeueAfter = remaining
+		} else {
+			status.PreviewColor = ""
+			status.PreviewVersion = ""
+			status.ScaleDownTime = nil
+		}
+	}
+
+	// Remove the rolling deployment once live traffic has been routed to the active color
+	if wasLive {
+		rolling := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+		if _, _, err := r.reconcileGenerated(ctx, app, removedGenerator{obj: rolling}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// removeBlueGreen deletes both colors once the rolling deployment has taken over again
+func (r *AppReconciler) removeBlueGreen(ctx context.Context, app *kappv1alpha1.App) (ctrl.Result, error) {
+	if app.Status.BlueGreen == nil || !workloadAvailable(app) {
+		return ctrl.Result{}, nil
+	}
+	for _, color := range []string{trackBlue, trackGreen} {
+		if _, _, err := r.reconcileGenerated(ctx, app, colorGenerator{r: r, color: color}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	app.Status.BlueGreen = nil
+	return ctrl.Result{}, nil
+}
+
+// colorGenerator produces the Deployment of one color of a blue/green App running version at
+// replicas, or removes it when version is empty. A nil replicas leaves the count to the
+// HorizontalPodAutoscaler.
+type colorGenerator struct {
+	generatorDefaults
+	r        *AppReconciler
+	color    string
+	version  string
+	replicas *int32
+}
+
+func (g colorGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: colorName(app, g.color), Namespace: app.Namespace}}
+}
+
+func (g colorGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if g.version == "" {
+		return nil, nil
+	}
+	dep, err := g.r.colorDeployment(app, g.color, g.version)
+	if err != nil {
+		return nil, err
+	}
+	dep.Spec.Replicas = g.replicas
+	return dep, nil
+}
+
+// liveReplicas returns the replica count of the App's Deployment named name, or 0 when there is none
+func (r *AppReconciler) liveReplicas(ctx context.Context, app *kappv1alpha1.App, name string) (int32, error) {
+	dep := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, dep)
+	if errors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dep.Spec.Replicas == nil {
+		return 1, nil
+	}
+	return *dep.Spec.Replicas, nil
+}
+
+// ready waits for the color's rollout to finish, recording the status of the active color on the App
+func (g colorGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	dep := obj.(*appsv1.Deployment)
+	if g.color == app.Status.BlueGreen.ActiveColor {
+		clearWorkloadStatus(app)
+		app.Status.Deployment = dep.Status.DeepCopy()
+	}
+	if !deploymentRolledOut(dep) {
+		return false, fmt.Sprintf("Waiting for %s deployment rollout to finish", g.color)
+	}
+	return true, ""
+}
+
+// colorDeployment derives a color from the App's deployment, running version under its own name and track label
+func (r *AppReconciler) colorDeployment(app *kappv1alpha1.App, color, version string) (*appsv1.Deployment, error) {
+	dep, err := r.deployment(app)
+	if err != nil {
+		return nil, err
+	}
+	dep.Name = colorName(app, color)
+	dep.Spec.Selector.MatchLabels[trackLabel] = color
+	dep.Spec.Template.Name = dep.Name
+	dep.Spec.Template.Labels[trackLabel] = color
+	setVersion(dep, app, version)
+	return dep, nil
+}
+
+// blueGreenEnabled reports whether the App is released with the blue/green strategy
+func blueGreenEnabled(app *kappv1alpha1.App) bool {
+	return app.Spec.BlueGreen != nil
+}
+
+// blueGreenPromoted reports whether the App's version has been promoted by spec field or annotation
+func blueGreenPromoted(app *kappv1alpha1.App) bool {
+	if app.Spec.BlueGreen.PromotedVersion == app.Spec.Version {
+		return true
+	}
+	return app.Annotations[kappv1alpha1.PromoteAnnotation] == app.Spec.Version
+}
+
+// blueGreenPreviewing reports whether a second color is running and can receive preview traffic
+func blueGreenPreviewing(app *kappv1alpha1.App) bool {
+	return blueGreenEnabled(app) && app.Status.BlueGreen != nil && app.Status.BlueGreen.PreviewColor != ""
+}
+
+// blueGreenLive reports whether the active color of a blue/green App receives live traffic
+func blueGreenLive(app *kappv1alpha1.App) bool {
+	return app.Status.BlueGreen != nil && app.Status.BlueGreen.Live
+}
+
+// liveTrack returns the track of the pods receiving live traffic
+func liveTrack(app *kappv1alpha1.App) string {
+	if blueGreenLive(app) {
+		return app.Status.BlueGreen.ActiveColor
+	}
+	return trackStable
+}
+
+// activeDeploymentName returns the name of the deployment receiving live traffic
+func activeDeploymentName(app *kappv1alpha1.App) string {
+	if blueGreenEnabled(app) && blueGreenLive(app) {
+		return colorName(app, app.Status.BlueGreen.ActiveColor)
+	}
+	return app.Name
+}
+
+func colorName(app *kappv1alpha1.App, color string) string {
+	return fmt.Sprintf("%s-%s", app.Name, color)
+}
+
+func otherColor(color string) string {
+	if color == trackBlue {
+		return trackGreen
+	}
+	return trackBlue
+}
+
+func scaleDownDelay(app *kappv1alpha1.App) time.Duration {
+	if app.Spec.BlueGreen.ScaleDownDelay == nil {
+		return defaultScaleDownDelay
+	}
+	return app.Spec.BlueGreen.ScaleDownDelay.Duration
+}
+
+func previewHeader(app *kappv1alpha1.App) string {
+	if app.Spec.BlueGreen.PreviewHeader == "" {
+		return defaultPreviewHeader
+	}
+	return strings.ToLower(app.Spec.BlueGreen.PreviewHeader)
+}
+
+// previewHostname returns the hostname the preview is exposed on, defaulting to the app name
+// suffixed with -preview under the platform domain
+func (r *AppReconciler) previewHostname(app *kappv1alpha1.App) string {
+	if app.Spec.BlueGreen.PreviewHostname != "" {
+		return app.Spec.BlueGreen.PreviewHostname
+	}
+	if r.Domain == "" {
+		return fmt.Sprintf("%s-preview", app.Name)
+	}
+	return fmt.Sprintf("%s-preview.%s", app.Name, r.Domain)
+}
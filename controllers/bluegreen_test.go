@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	security "istio.io/client-go/pkg/apis/security/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"testing"
+	"time"
+)
+
+// testScheme returns a scheme with the built-in kinds, the Istio APIs and the kappa API registered
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := istio.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := security.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kappv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+// TestReconcileBlueGreen walks an App through a release: the first version goes live in blue,
+// the next one waits in green until promoted, and blue is removed once the scale down delay passed.
+func TestReconcileBlueGreen(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "uid"},
+		Spec: kappv1alpha1.AppSpec{
+			Image:           "registry/web",
+			Version:         "v1",
+			Instances:       pointer.Int32Ptr(1),
+			Cpu:             "200m",
+			Memory:          "256Mi",
+			Port:            pointer.Int32Ptr(8080),
+			HealthCheckType: kappv1alpha1.HealthCheckTCP,
+			BlueGreen:       &kappv1alpha1.BlueGreen{ScaleDownDelay: &metav1.Duration{Duration: time.Minute}},
+		},
+	}
+	rolling := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	if err := ctrl.SetControllerReference(app, rolling, scheme); err != nil {
+		t.Fatal(err)
+	}
+	r := &AppReconciler{
+		Client: newApplyClient(scheme, rolling),
+		Log:    ctrl.Log,
+		Scheme: scheme,
+	}
+
+	reconcile := func() ctrl.Result {
+		t.Helper()
+		result, err := r.reconcileBlueGreen(ctx, ctrl.Request{}, app)
+		if err != nil {
+			t.Fatalf("reconcileBlueGreen: %v", err)
+		}
+		return result
+	}
+	get := func(name string) *appsv1.Deployment {
+		t.Helper()
+		dep := &appsv1.Deployment{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: "team"}, dep)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dep
+	}
+	markAvailable := func(name string) {
+		t.Helper()
+		dep := get(name)
+		dep.Status = appsv1.DeploymentStatus{UpdatedReplicas: *dep.Spec.Replicas, AvailableReplicas: *dep.Spec.Replicas}
+		if err := r.Update(ctx, dep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image := func(dep *appsv1.Deployment) string {
+		return dep.Spec.Template.Spec.Containers[0].Image
+	}
+
+	// The first version goes live in blue without waiting for promotion
+	reconcile()
+	status := app.Status.BlueGreen
+	if status.ActiveColor != trackBlue || status.ActiveVersion != "v1" || status.PreviewColor != "" {
+		t.Fatalf("first version: status %+v, want v1 live in blue", status)
+	}
+	if blue := get("web-blue"); blue == nil || image(blue) != "registry/web:v1" {
+		t.Fatalf("blue deployment %v, want it running v1", blue)
+	}
+	if status.Live || get("web") == nil {
+		t.Fatal("live traffic moved off the rolling deployment before blue was available")
+	}
+
+	// Traffic switches once blue is available, and the rolling deployment goes on the next pass
+	markAvailable("web-blue")
+	reconcile()
+	if !status.Live || get("web") == nil {
+		t.Fatalf("live %v with the rolling deployment removed, want live traffic switched first", status.Live)
+	}
+	reconcile()
+	if get("web") != nil {
+		t.Fatal("rolling deployment kept after blue went live")
+	}
+
+	// A new version is previewed in green while blue keeps live traffic
+	app.Spec.Version = "v2"
+	reconcile()
+	if status.ActiveColor != trackBlue || status.PreviewColor != trackGreen || status.PreviewVersion != "v2" {
+		t.Fatalf("new version: status %+v, want v2 previewed in green", status)
+	}
+	if green := get("web-green"); green == nil || image(green) != "registry/web:v2" {
+		t.Fatalf("green deployment %v, want it running v2", green)
+	}
+
+	markAvailable("web-green")
+	reconcile()
+	if status.ActiveColor != trackBlue {
+		t.Fatalf("active color %q before promotion, want blue", status.ActiveColor)
+	}
+
+	// Promotion switches live traffic and keeps blue around for the scale down delay
+	app.Annotations = map[string]string{kappv1alpha1.PromoteAnnotation: "v2"}
+	result := reconcile()
+	if status.ActiveColor != trackGreen || status.ActiveVersion != "v2" || status.PreviewColor != trackBlue || status.PreviewVersion != "v1" {
+		t.Fatalf("promoted: status %+v, want v2 live in green and v1 in blue", status)
+	}
+	if status.ScaleDownTime == nil || result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute {
+		t.Fatalf("promoted: scale down at %v and requeue after %v, want both after the delay", status.ScaleDownTime, result.RequeueAfter)
+	}
+
+	result = reconcile()
+	if get("web-blue") == nil || result.RequeueAfter <= 0 {
+		t.Fatalf("blue removed or not rechecked (requeue after %v) within the scale down delay", result.RequeueAfter)
+	}
+
+	// Once the delay has passed the previous color is deleted
+	passed := metav1.NewTime(time.Now().Add(-time.Second))
+	status.ScaleDownTime = &passed
+	reconcile()
+	if get("web-blue") != nil {
+		t.Fatal("blue kept after the scale down delay")
+	}
+	if status.PreviewColor != "" || status.PreviewVersion != "" || status.ScaleDownTime != nil {
+		t.Fatalf("after scale down: status %+v, want no preview", status)
+	}
+	if get("web-green") == nil {
+		t.Fatal("live green deployment deleted")
+	}
+}
+
+// An autoscaled App's colors start at the scale the HorizontalPodAutoscaler reached
+func TestBlueGreenStartsAtLiveScale(t *testing.T) {
+	scheme := testScheme(t)
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "uid"},
+		Spec: kappv1alpha1.AppSpec{
+			Image:           "registry/web",
+			Version:         "v1",
+			Cpu:             "200m",
+			Memory:          "256Mi",
+			Port:            pointer.Int32Ptr(8080),
+			HealthCheckType: kappv1alpha1.HealthCheckTCP,
+			Autoscaling:     &kappv1alpha1.Autoscaling{MinInstances: pointer.Int32Ptr(2), MaxInstances: 10},
+			BlueGreen:       &kappv1alpha1.BlueGreen{},
+		},
+	}
+	rolling := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32Ptr(6)},
+	}
+	r := &AppReconciler{Client: newApplyClient(scheme, rolling), Log: ctrl.Log, Scheme: scheme}
+
+	if _, err := r.reconcileBlueGreen(context.Background(), ctrl.Request{}, app); err != nil {
+		t.Fatal(err)
+	}
+	blue := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "web-blue", Namespace: "team"}, blue); err != nil {
+		t.Fatal(err)
+	}
+	if *blue.Spec.Replicas != 6 {
+		t.Errorf("blue starts at %d replicas, want the rolling deployment's 6", *blue.Spec.Replicas)
+	}
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// budgetRecheckInterval is how often an App held below its scale by its Environment's budget checks
+// whether other Apps made room
+const budgetRecheckInterval = time.Minute
+
+// Requests of an injected Istio proxy, unless overridden by the pod's annotations
+const (
+	istioProxyCpu    = "100m"
+	istioProxyMemory = "128Mi"
+)
+
+// demand is what an App's pods request: every instance of its workload at its largest scale, and
+// the pods of a canary or blue/green color running alongside them
+type demand struct {
+	// pod holds the cpu and memory requests of one instance, including its Istio proxy
+	pod corev1.ResourceList
+	// instances of the App's workload
+	instances int64
+	// extra pods running a canary, a blue/green preview or the previous color
+	extra int64
+}
+
+// total returns the requests of every pod counted by the demand
+func (d demand) total(name corev1.ResourceName) resource.Quantity {
+	q := d.pod[name]
+	return *resource.NewMilliQuantity(q.MilliValue()*(d.instances+d.extra), q.Format)
+}
+
+// usage is what the other Apps in an Environment request in total
+type usage struct {
+	cpu       resource.Quantity
+	memory    resource.Quantity
+	instances int64
+}
+
+func (u *usage) add(d demand) {
+	cpu, memory := d.total(corev1.ResourceCPU), d.total(corev1.ResourceMemory)
+	u.cpu.Add(cpu)
+	u.memory.Add(memory)
+	u.instances += d.instances + d.extra
+}
+
+// reconcileBudget holds the App at the instances that fit in what the other Apps leave of its
+// Environment's budget, recording them in status.allowedInstances and setting the WithinBudget
+// condition. Apps outside of an Environment always fit.
+func (r *AppReconciler) reconcileBudget(ctx context.Context, app *kappv1alpha1.App) error {
+	allowed, message, err := r.checkBudget(ctx, app)
+	if err != nil {
+		return err
+	}
+	app.Status.AllowedInstances = allowed
+	if allowed == nil {
+		r.setCondition(app, kappv1alpha1.ConditionWithinBudget, metav1.ConditionTrue, "WithinBudget", "")
+		return nil
+	}
+	r.setCondition(app, kappv1alpha1.ConditionWithinBudget, metav1.ConditionFalse, "OverBudget", message)
+	return nil
+}
+
+// checkBudget returns the instances the App is held at, or nil when it fits at its largest scale
+func (r *AppReconciler) checkBudget(ctx context.Context, app *kappv1alpha1.App) (*int32, string, error) {
+	env := &kappv1alpha1.Environment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: app.Namespace}, env); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	budget := env.Spec.Budget
+	if budget == nil {
+		return nil, "", nil
+	}
+	proxies := env.Spec.IstioInjection == nil || *env.Spec.IstioInjection
+
+	apps := &kappv1alpha1.AppList{}
+	if err := r.List(ctx, apps, client.InNamespace(app.Namespace)); err != nil {
+		return nil, "", err
+	}
+	var used usage
+	for i := range apps.Items {
+		other := &apps.Items[i]
+		if other.Name == app.Name || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		// Count the other App as it was last reconciled, with its Environment defaults
+		if other.Status.EffectiveSpec != nil {
+			other.Spec = *other.Status.EffectiveSpec
+		}
+		otherDemand, err := r.demand(other, proxies)
+		if err != nil {
+			// An App whose pods can't be built runs nothing
+			continue
+		}
+		if allowed := other.Status.AllowedInstances; allowed != nil && int64(*allowed) < otherDemand.instances {
+			otherDemand.instances = int64(*allowed)
+		}
+		used.add(otherDemand)
+	}
+	needed, err := r.demand(app, proxies)
+	if err != nil {
+		return nil, "", err
+	}
+
+	allowed, message := fitBudget(budget, used, needed)
+	if allowed >= needed.instances {
+		return nil, "", nil
+	}
+	held := int32(allowed)
+	return &held, message, nil
+}
+
+// fitBudget returns how many instances of needed fit in what used leaves of budget, explaining
+// what limits them when not all of them do
+func fitBudget(budget *kappv1alpha1.Budget, used usage, needed demand) (int64, string) {
+	allowed, message := needed.instances, ""
+	for _, limit := range []struct {
+		name   corev1.ResourceName
+		budget *resource.Quantity
+		used   resource.Quantity
+	}{
+		{corev1.ResourceCPU, budget.Cpu, used.cpu},
+		{corev1.ResourceMemory, budget.Memory, used.memory},
+	} {
+		pod := needed.pod[limit.name]
+		if limit.budget == nil || pod.IsZero() {
+			continue
+		}
+		remaining := limit.budget.DeepCopy()
+		remaining.Sub(limit.used)
+		fit := remaining.MilliValue()/pod.MilliValue() - needed.extra
+		if fit < allowed {
+			allowed = fit
+			message = fmt.Sprintf("%s %s of the Environment's %s budget remains", remaining.String(), limit.name, limit.budget.String())
+		}
+	}
+	if budget.Instances != nil {
+		remaining := int64(*budget.Instances) - used.instances
+		if fit := remaining - needed.extra; fit < allowed {
+			allowed = fit
+			message = fmt.Sprintf("%d of the Environment's %d instances remain", remaining, *budget.Instances)
+		}
+	}
+
+	if allowed < 0 {
+		allowed = 0
+	}
+	if allowed < needed.instances {
+		message = fmt.Sprintf("App is held at %d of %d instances: %s", allowed, needed.instances, message)
+	}
+	return allowed, message
+}
+
+// demand returns what the App's pods request at its largest scale, counting an Istio proxy in
+// each pod it is injected into when proxies are injected in the namespace
+func (r *AppReconciler) demand(app *kappv1alpha1.App, proxies bool) (demand, error) {
+	dep, err := r.deployment(app)
+	if err != nil {
+		return demand{}, err
+	}
+	requests := podRequests(&dep.Spec.Template.Spec)
+	pod := corev1.ResourceList{
+		corev1.ResourceCPU:    requests[corev1.ResourceCPU],
+		corev1.ResourceMemory: requests[corev1.ResourceMemory],
+	}
+	// Job pods run without a proxy, see jobSpec
+	annotations := dep.Spec.Template.Annotations
+	if proxies && !batchWorkload(app) && annotations["sidecar.istio.io/inject"] != "false" {
+		for _, proxy := range []struct {
+			name       corev1.ResourceName
+			annotation string
+			request    string
+		}{
+			{corev1.ResourceCPU, "sidecar.istio.io/proxyCPU", istioProxyCpu},
+			{corev1.ResourceMemory, "sidecar.istio.io/proxyMemory", istioProxyMemory},
+		} {
+			request := proxy.request
+			if value, ok := annotations[proxy.annotation]; ok {
+				request = value
+			}
+			if q, err := resource.ParseQuantity(request); err == nil {
+				total := pod[proxy.name]
+				total.Add(q)
+				pod[proxy.name] = total
+			}
+		}
+	}
+
+	instances := int64(maxInstances(app))
+	var extra int64
+	if canaryEnabled(app) {
+		extra = 1
+		if app.Spec.Canary.Instances != nil {
+			extra = int64(*app.Spec.Canary.Instances)
+		}
+	}
+	// A preview or previous color runs at the scale of the active one
+	if bg := app.Status.BlueGreen; blueGreenEnabled(app) && bg != nil && (bg.PreviewColor != "" || bg.ActiveVersion != app.Spec.Version) {
+		extra = instances
+	}
+
+	return demand{pod: pod, instances: instances, extra: extra}, nil
+}
+
+// budgetInstances caps n at the instances the Environment's budget holds the App at, if any
+func budgetInstances(app *kappv1alpha1.App, n int32) int32 {
+	if allowed := app.Status.AllowedInstances; allowed != nil && *allowed < n {
+		return *allowed
+	}
+	return n
+}
+
+// podRequests returns what the scheduler reserves for a pod: the containers' requests added up,
+// or the largest init container's request when that is higher
+func podRequests(pod *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, c := range pod.Containers {
+		for name, q := range c.Resources.Requests {
+			total := requests[name]
+			total.Add(q)
+			requests[name] = total
+		}
+	}
+	for _, c := range pod.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if total, ok := requests[name]; !ok || q.Cmp(total) > 0 {
+				requests[name] = q.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestDemand(t *testing.T) {
+	r := &AppReconciler{}
+
+	tests := []struct {
+		name      string
+		spec      kappv1alpha1.AppSpec
+		status    kappv1alpha1.AppStatus
+		proxies   bool
+		cpu       string
+		memory    string
+		instances int64
+		extra     int64
+	}{
+		{
+			name:      "defaults with a proxy",
+			proxies:   true,
+			cpu:       "300m",
+			memory:    "384Mi",
+			instances: 1,
+		},
+		{
+			name:      "proxies not injected in the namespace",
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 1,
+		},
+		{
+			name:      "sidecar disabled",
+			spec:      kappv1alpha1.AppSpec{DisableSidecar: pointer.BoolPtr(true)},
+			proxies:   true,
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 1,
+		},
+		{
+			name:      "proxy requests from annotations",
+			spec:      kappv1alpha1.AppSpec{Annotations: map[string]string{"sidecar.istio.io/proxyCPU": "50m", "sidecar.istio.io/proxyMemory": "64Mi"}},
+			proxies:   true,
+			cpu:       "250m",
+			memory:    "320Mi",
+			instances: 1,
+		},
+		{
+			name:      "sidecar containers",
+			spec:      kappv1alpha1.AppSpec{Sidecars: []kappv1alpha1.Sidecar{{Name: "proxy", Image: "envoy", Cpu: "100m", Memory: "64Mi"}}},
+			cpu:       "300m",
+			memory:    "320Mi",
+			instances: 1,
+		},
+		{
+			name:      "autoscaled to its maximum",
+			spec:      kappv1alpha1.AppSpec{Autoscaling: &kappv1alpha1.Autoscaling{MaxInstances: 4}},
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 4,
+		},
+		{
+			name:      "canary",
+			spec:      kappv1alpha1.AppSpec{Instances: pointer.Int32Ptr(3), Canary: &kappv1alpha1.Canary{Version: "v2", Instances: pointer.Int32Ptr(2)}},
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 3,
+			extra:     2,
+		},
+		{
+			name:      "canary of the running version",
+			spec:      kappv1alpha1.AppSpec{Version: "v2", Canary: &kappv1alpha1.Canary{Version: "v2"}},
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 1,
+		},
+		{
+			name:      "blue/green preview",
+			spec:      kappv1alpha1.AppSpec{Version: "v2", Instances: pointer.Int32Ptr(2), BlueGreen: &kappv1alpha1.BlueGreen{}},
+			status:    kappv1alpha1.AppStatus{BlueGreen: &kappv1alpha1.BlueGreenStatus{ActiveColor: "blue", ActiveVersion: "v1"}},
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 2,
+			extra:     2,
+		},
+		{
+			name:      "blue/green without a preview",
+			spec:      kappv1alpha1.AppSpec{Version: "v1", Instances: pointer.Int32Ptr(2), BlueGreen: &kappv1alpha1.BlueGreen{}},
+			status:    kappv1alpha1.AppStatus{BlueGreen: &kappv1alpha1.BlueGreenStatus{ActiveColor: "blue", ActiveVersion: "v1"}},
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 2,
+		},
+		{
+			name:      "job without a proxy",
+			spec:      kappv1alpha1.AppSpec{WorkloadKind: kappv1alpha1.WorkloadJob, Instances: pointer.Int32Ptr(3)},
+			proxies:   true,
+			cpu:       "200m",
+			memory:    "256Mi",
+			instances: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}, Spec: tt.spec, Status: tt.status}
+			d, err := r.demand(app, tt.proxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cpu, memory := d.pod[corev1.ResourceCPU], d.pod[corev1.ResourceMemory]
+			if cpu.Cmp(resource.MustParse(tt.cpu)) != 0 || memory.Cmp(resource.MustParse(tt.memory)) != 0 {
+				t.Errorf("pod requests %s cpu and %s memory, want %s and %s", cpu.String(), memory.String(), tt.cpu, tt.memory)
+			}
+			if d.instances != tt.instances || d.extra != tt.extra {
+				t.Errorf("instances = %d and %d extra, want %d and %d", d.instances, d.extra, tt.instances, tt.extra)
+			}
+		})
+	}
+}
+
+func TestFitBudget(t *testing.T) {
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+	pod := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")}
+
+	tests := []struct {
+		name    string
+		budget  kappv1alpha1.Budget
+		used    usage
+		needed  demand
+		allowed int64
+	}{
+		{
+			name:    "no limits",
+			needed:  demand{pod: pod, instances: 10},
+			allowed: 10,
+		},
+		{
+			name:    "fits",
+			budget:  kappv1alpha1.Budget{Cpu: quantity("4"), Memory: quantity("8Gi"), Instances: pointer.Int32Ptr(8)},
+			needed:  demand{pod: pod, instances: 4},
+			allowed: 4,
+		},
+		{
+			name:    "held by cpu",
+			budget:  kappv1alpha1.Budget{Cpu: quantity("2")},
+			used:    usage{cpu: resource.MustParse("500m")},
+			needed:  demand{pod: pod, instances: 4},
+			allowed: 3,
+		},
+		{
+			name:    "held by memory",
+			budget:  kappv1alpha1.Budget{Memory: quantity("4Gi")},
+			used:    usage{memory: resource.MustParse("2Gi")},
+			needed:  demand{pod: pod, instances: 4},
+			allowed: 2,
+		},
+		{
+			name:    "held by instances",
+			budget:  kappv1alpha1.Budget{Instances: pointer.Int32Ptr(5)},
+			used:    usage{instances: 3},
+			needed:  demand{pod: pod, instances: 4},
+			allowed: 2,
+		},
+		{
+			name:    "room kept for extra pods",
+			budget:  kappv1alpha1.Budget{Cpu: quantity("2")},
+			needed:  demand{pod: pod, instances: 4, extra: 1},
+			allowed: 3,
+		},
+		{
+			name:    "no room left",
+			budget:  kappv1alpha1.Budget{Cpu: quantity("1"), Instances: pointer.Int32Ptr(2)},
+			used:    usage{cpu: resource.MustParse("2"), instances: 3},
+			needed:  demand{pod: pod, instances: 2},
+			allowed: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, message := fitBudget(&tt.budget, tt.used, tt.needed)
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %d, want %d", allowed, tt.allowed)
+			}
+			if (message == "") != (allowed == tt.needed.instances) {
+				t.Errorf("message = %q with %d of %d instances allowed", message, allowed, tt.needed.instances)
+			}
+		})
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kappv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cpu := resource.MustParse("1")
+	newApp := func(name string, instances int32, allowed *int32) *kappv1alpha1.App {
+		return &kappv1alpha1.App{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team"},
+			Spec:       kappv1alpha1.AppSpec{Instances: pointer.Int32Ptr(instances)},
+			Status:     kappv1alpha1.AppStatus{AllowedInstances: allowed},
+		}
+	}
+	env := &kappv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec:       kappv1alpha1.EnvironmentSpec{IstioInjection: pointer.BoolPtr(false), Budget: &kappv1alpha1.Budget{Cpu: &cpu}},
+	}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		app     *kappv1alpha1.App
+		allowed *int32
+	}{
+		{
+			name: "outside of an Environment",
+			app:  newApp("web", 10, nil),
+		},
+		{
+			name:    "fits",
+			objects: []client.Object{env},
+			app:     newApp("web", 5, nil),
+		},
+		{
+			name:    "held by the other Apps",
+			objects: []client.Object{env, newApp("api", 2, nil)},
+			app:     newApp("web", 5, nil),
+			allowed: pointer.Int32Ptr(3),
+		},
+		{
+			name:    "other Apps count at the instances they are held at",
+			objects: []client.Object{env, newApp("api", 4, pointer.Int32Ptr(1))},
+			app:     newApp("web", 5, nil),
+			allowed: pointer.Int32Ptr(4),
+		},
+		{
+			name:    "released once there is room",
+			objects: []client.Object{env},
+			app:     newApp("web", 2, pointer.Int32Ptr(1)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &AppReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(tt.objects, tt.app)...).Build()}
+			allowed, message, err := r.checkBudget(context.Background(), tt.app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (allowed == nil) != (tt.allowed == nil) || (allowed != nil && *allowed != *tt.allowed) {
+				t.Errorf("allowed = %v, want %v (%s)", allowed, tt.allowed, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// trackLabel distinguishes the pods of each deployment an App runs, so traffic can be split between them
+const trackLabel = "kappa.io/track"
+
+const (
+	trackStable = "stable"
+	trackCanary = "canary"
+)
+
+var defaultCanarySteps = []int32{10, 25, 50, 100}
+
+const defaultCanaryStepInterval = 5 * time.Minute
+
+// canaryGenerator produces the Deployment running the canary version while a canary is requested
+type canaryGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g canaryGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: canaryName(app), Namespace: app.Namespace}}
+}
+
+func (g canaryGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !canaryEnabled(app) {
+		return nil, nil
+	}
+	return g.r.canaryDeployment(app)
+}
+
+// ready waits for every canary instance to be updated and available
+func (g canaryGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	if !deploymentRolledOut(obj.(*appsv1.Deployment)) {
+		return false, "Waiting for canary rollout to finish"
+	}
+	return true, ""
+}
+
+func (r *AppReconciler) reconcileCanary(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
+	// Tear the canary down once it has been promoted or abandoned
+	if !canaryEnabled(app) {
+		app.Status.Canary = nil
+		_, _, err := r.reconcileGenerated(ctx, app, canaryGenerator{r: r})
+		return ctrl.Result{}, err
+	}
+
+	// Start from the first step whenever a new canary version is released
+	if app.Status.Canary == nil || app.Status.Canary.Version != app.Spec.Canary.Version {
+		r.Log.Info("Starting canary", "Name", app.Name, "Namespace", app.Namespace, "Version", app.Spec.Canary.Version)
+		app.Status.Canary = &kappv1alpha1.CanaryStatus{Version: app.Spec.Canary.Version}
+		setCanaryStep(app, 0)
+	}
+
+	clampCanaryStep(app)
+
+	available, _, err := r.reconcileGenerated(ctx, app, canaryGenerator{r: r})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.advanceCanary(app, available), nil
+}
+
+// advanceCanary moves to the next step once the canary is available and the step interval has elapsed
+func (r *AppReconciler) advanceCanary(app *kappv1alpha1.App, available bool) ctrl.Result {
+	status := app.Status.Canary
+	steps := canarySteps(app)
+	if int(status.Step) >= len(steps)-1 {
+		status.Complete = true
+		return ctrl.Result{}
+	}
+
+	// Hold the current step until every canary instance is available
+	if !available {
+		return ctrl.Result{}
+	}
+
+	interval := canaryStepInterval(app)
+	elapsed := time.Since(status.StepStartTime.Time)
+	if elapsed < interval {
+		return ctrl.Result{RequeueAfter: interval - elapsed}
+	}
+
+	setCanaryStep(app, status.Step+1)
+	r.Log.Info("Advancing canary", "Name", app.Name, "Namespace", app.Namespace, "Step", status.Step, "Weight", status.CanaryWeight)
+	if status.Complete {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: interval}
+}
+
+func setCanaryStep(app *kappv1alpha1.App, step int32) {
+	steps := canarySteps(app)
+	now := metav1.Now()
+	status := app.Status.Canary
+	status.Step = step
+	status.CanaryWeight = steps[step]
+	status.StableWeight = 100 - steps[step]
+	status.StepStartTime = &now
+	status.Complete = int(step) >= len(steps)-1
+}
+
+// clampCanaryStep keeps the canary on a step that still exists after its steps changed, at the
+// weight the current steps give it
+func clampCanaryStep(app *kappv1alpha1.App) {
+	status := app.Status.Canary
+	steps := canarySteps(app)
+	step := status.Step
+	if int(step) > len(steps)-1 {
+		step = int32(len(steps) - 1)
+	}
+	if step != status.Step || status.CanaryWeight != steps[step] {
+		setCanaryStep(app, step)
+	}
+}
+
+// canaryEnabled reports whether a canary of a version other than the current one is requested
+func canaryEnabled(app *kappv1alpha1.App) bool {
+	return app.Spec.Canary != nil && app.Spec.Canary.Version != app.Spec.Version
+}
+
+// canaryActive reports whether traffic should currently be split with the canary
+func canaryActive(app *kappv1alpha1.App) bool {
+	return canaryEnabled(app) && app.Status.Canary != nil && app.Status.Canary.Version == app.Spec.Canary.Version
+}
+
+func canaryName(app *kappv1alpha1.App) string {
+	return fmt.Sprintf("%s-%s", app.Name, trackCanary)
+}
+
+func canarySteps(app *kappv1alpha1.App) []int32 {
+	if len(app.Spec.Canary.Steps) == 0 {
+		return defaultCanarySteps
+	}
+	return app.Spec.Canary.Steps
+}
+
+func canaryStepInterval(app *kappv1alpha1.App) time.Duration {
+	if app.Spec.Canary.StepInterval == nil {
+		return defaultCanaryStepInterval
+	}
+	return app.Spec.Canary.StepInterval.Duration
+}
+
+// canaryDeployment derives the canary from the App's deployment, running the canary version
+// under its own name and track label
+func (r *AppReconciler) canaryDeployment(app *kappv1alpha1.App) (*appsv1.Deployment, error) {
+	dep, err := r.deployment(app)
+	if err != nil {
+		return nil, err
+	}
+	dep.Name = canaryName(app)
+
+	replicas := int32(1)
+	if app.Spec.Canary.Instances != nil {
+		replicas = *app.Spec.Canary.Instances
+	}
+	dep.Spec.Replicas = &replicas
+
+	dep.Spec.Selector.MatchLabels[trackLabel] = trackCanary
+	dep.Spec.Template.Name = dep.Name
+	dep.Spec.Template.Labels[trackLabel] = trackCanary
+	setVersion(dep, app, app.Spec.Canary.Version)
+
+	return dep, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"testing"
+	"time"
+)
+
+func TestSetCanaryStep(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []int32
+		step     int32
+		canary   int32
+		stable   int32
+		complete bool
+	}{
+		{name: "first default step", step: 0, canary: 10, stable: 90},
+		{name: "last default step", step: 3, canary: 100, stable: 0, complete: true},
+		{name: "custom steps", steps: []int32{20, 60}, step: 0, canary: 20, stable: 80},
+		{name: "single step", steps: []int32{100}, step: 0, canary: 100, stable: 0, complete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{Spec: kappv1alpha1.AppSpec{Canary: &kappv1alpha1.Canary{Version: "v2", Steps: tt.steps}}}
+			app.Status.Canary = &kappv1alpha1.CanaryStatus{Version: "v2"}
+			setCanaryStep(app, tt.step)
+
+			status := app.Status.Canary
+			if status.Step != tt.step || status.CanaryWeight != tt.canary || status.StableWeight != tt.stable || status.Complete != tt.complete {
+				t.Errorf("step %d at %d/%d complete %v, want %d at %d/%d complete %v",
+					status.Step, status.CanaryWeight, status.StableWeight, status.Complete, tt.step, tt.canary, tt.stable, tt.complete)
+			}
+			if status.StepStartTime == nil {
+				t.Error("step start time not recorded")
+			}
+		})
+	}
+}
+
+func TestAdvanceCanary(t *testing.T) {
+	r := &AppReconciler{Log: ctrl.Log}
+	interval := 5 * time.Minute
+
+	tests := []struct {
+		name      string
+		step      int32
+		elapsed   time.Duration
+		available bool
+		wantStep  int32
+		complete  bool
+		requeue   bool
+	}{
+		{name: "held until available", step: 0, elapsed: time.Hour, wantStep: 0},
+		{name: "held until the interval elapsed", step: 1, elapsed: time.Minute, available: true, wantStep: 1, requeue: true},
+		{name: "advances after the interval", step: 1, elapsed: interval, available: true, wantStep: 2, requeue: true},
+		{name: "completes on the last step", step: 2, elapsed: interval, available: true, wantStep: 3, complete: true},
+		{name: "stays complete", step: 3, elapsed: time.Hour, available: true, wantStep: 3, complete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{Spec: kappv1alpha1.AppSpec{Canary: &kappv1alpha1.Canary{
+				Version:      "v2",
+				StepInterval: &metav1.Duration{Duration: interval},
+			}}}
+			app.Status.Canary = &kappv1alpha1.CanaryStatus{Version: "v2"}
+			setCanaryStep(app, tt.step)
+			started := metav1.NewTime(time.Now().Add(-tt.elapsed))
+			app.Status.Canary.StepStartTime = &started
+
+			result := r.advanceCanary(app, tt.available)
+
+			status := app.Status.Canary
+			if status.Step != tt.wantStep || status.Complete != tt.complete {
+				t.Errorf("step %d complete %v, want %d complete %v", status.Step, status.Complete, tt.wantStep, tt.complete)
+			}
+			if status.CanaryWeight != defaultCanarySteps[tt.wantStep] || status.StableWeight != 100-defaultCanarySteps[tt.wantStep] {
+				t.Errorf("weights %d/%d, want the weights of step %d", status.CanaryWeight, status.StableWeight, tt.wantStep)
+			}
+			if requeue := result.RequeueAfter > 0; requeue != tt.requeue || result.RequeueAfter > interval {
+				t.Errorf("requeue after %v, want requeue %v within %v", result.RequeueAfter, tt.requeue, interval)
+			}
+		})
+	}
+}
+
+func TestClampCanaryStep(t *testing.T) {
+	app := &kappv1alpha1.App{Spec: kappv1alpha1.AppSpec{Canary: &kappv1alpha1.Canary{Version: "v2"}}}
+	app.Status.Canary = &kappv1alpha1.CanaryStatus{Version: "v2"}
+	setCanaryStep(app, 2)
+	started := app.Status.Canary.StepStartTime
+
+	clampCanaryStep(app)
+	if app.Status.Canary.StepStartTime != started {
+		t.Error("step restarted without a change to the steps")
+	}
+
+	// Fewer steps than the current one: stay on the last
+	app.Spec.Canary.Steps = []int32{30, 60}
+	clampCanaryStep(app)
+	if status := app.Status.Canary; status.Step != 1 || status.CanaryWeight != 60 || status.StableWeight != 40 || !status.Complete {
+		t.Errorf("step %d at %d/%d complete %v, want the last step, 1 at 60/40", status.Step, status.CanaryWeight, status.StableWeight, status.Complete)
+	}
+
+	// The same number of steps at other weights: stay on the step at its new weight
+	app.Spec.Canary.Steps = []int32{30, 40, 100}
+	clampCanaryStep(app)
+	if status := app.Status.Canary; status.Step != 1 || status.CanaryWeight != 40 {
+		t.Errorf("step %d at %d, want step 1 at 40", status.Step, status.CanaryWeight)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const configVolumeName = "config"
+
+// configMapGenerator produces the ConfigMap holding the App's config files
+type configMapGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g configMapGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g configMapGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	// Remove a previously created ConfigMap once the config has been emptied
+	if len(app.Spec.Config) == 0 {
+		return nil, nil
+	}
+	return g.r.configMap(app), nil
+}
+
+func (r *AppReconciler) configMap(app *kappv1alpha1.App) *corev1.ConfigMap {
+	labels := appLabels(app)
+
+	data := make(map[string]string)
+	for k, v := range app.Spec.Config {
+		data[k] = v
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        app.Name,
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: app.Spec.Annotations,
+		},
+		Data: data,
+	}
+}
+
+// configMapMountPath returns the directory the App's config files are mounted at
+func configMapMountPath(app *kappv1alpha1.App) string {
+	if app.Spec.ConfigMountPath == "" {
+		return "/config"
+	}
+	return app.Spec.ConfigMountPath
+}
+
+// configVolumes returns the pod volumes and container mounts for the App's config, if it has any
+func configVolumes(app *kappv1alpha1.App) ([]corev1.Volume, []corev1.VolumeMount) {
+	if len(app.Spec.Config) == 0 {
+		return nil, nil
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: app.Name,
+					},
+					DefaultMode: pointer.Int32Ptr(0644),
+				},
+			},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{
+			Name:      configVolumeName,
+			MountPath: configMapMountPath(app),
+			ReadOnly:  true,
+		},
+	}
+
+	return volumes, mounts
+}
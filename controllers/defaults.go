@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+)
+
+// applyEnvironmentDefaults merges the App defaults of the Environment owning the App's namespace
+// under the App's spec, and records the result in the App's status. The App is left as is
+// outside of an Environment.
+func (r *AppReconciler) applyEnvironmentDefaults(ctx context.Context, app *kappv1alpha1.App) error {
+	env := &kappv1alpha1.Environment{}
+	err := r.Get(ctx, types.NamespacedName{Name: app.Namespace}, env)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	var inherited []string
+	if err == nil && env.Spec.AppDefaults != nil {
+		inherited = mergeAppDefaults(&app.Spec, env.Spec.AppDefaults)
+	}
+
+	app.Status.EffectiveSpec = app.Spec.DeepCopy()
+	app.Status.Inherited = inherited
+	return nil
+}
+
+// mergeAppDefaults fills in spec from defaults, returning the paths of the fields it filled in
+func mergeAppDefaults(spec *kappv1alpha1.AppSpec, defaults *kappv1alpha1.AppDefaults) []string {
+	var inherited []string
+
+	for _, f := range []struct {
+		name     string
+		value    *string
+		fallback string
+	}{
+		{"cpu", &spec.Cpu, defaults.Cpu},
+		{"memory", &spec.Memory, defaults.Memory},
+	} {
+		if *f.value == "" && f.fallback != "" {
+			*f.value = f.fallback
+			inherited = append(inherited, f.name)
+		}
+	}
+
+	if spec.Resources == nil && defaults.Resources != nil {
+		spec.Resources = defaults.Resources.DeepCopy()
+		inherited = append(inherited, "resources")
+	}
+
+	for _, f := range []struct {
+		name     string
+		value    **bool
+		fallback *bool
+	}{
+		{"readOnlyRootFilesystem", &spec.ReadOnlyRootFilesystem, defaults.ReadOnlyRootFilesystem},
+		{"disableMtls", &spec.DisableMtls, defaults.DisableMtls},
+		{"disableSidecar", &spec.DisableSidecar, defaults.DisableSidecar},
+		{"public", &spec.Public, defaults.Public},
+	} {
+		// Workers receive no traffic, so they can't be made public
+		if f.name == "public" && spec.Worker != nil && *spec.Worker {
+			continue
+		}
+		if *f.value == nil && f.fallback != nil {
+			value := *f.fallback
+			*f.value = &value
+			inherited = append(inherited, f.name)
+		}
+	}
+
+	for _, f := range []struct {
+		name     string
+		value    *map[string]string
+		fallback map[string]string
+	}{
+		{"nodeSelector", &spec.NodeSelector, defaults.NodeSelector},
+		{"labels", &spec.Labels, defaults.Labels},
+		{"annotations", &spec.Annotations, defaults.Annotations},
+	} {
+		keys := make([]string, 0, len(f.fallback))
+		for k := range f.fallback {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, ok := (*f.value)[k]; ok {
+				continue
+			}
+			if *f.value == nil {
+				*f.value = make(map[string]string)
+			}
+			(*f.value)[k] = f.fallback[k]
+			inherited = append(inherited, fmt.Sprintf("%s.%s", f.name, k))
+		}
+	}
+
+	// Inherited variables come first, so the App's own can refer to them
+	var env []corev1.EnvVar
+	for _, v := range defaults.Env {
+		if !hasEnvVar(spec.Env, v.Name) {
+			env = append(env, v)
+			inherited = append(inherited, fmt.Sprintf("env[%s]", v.Name))
+		}
+	}
+	spec.Env = append(env, spec.Env...)
+
+	var secrets []string
+	for _, secret := range defaults.Secrets {
+		if !containsString(spec.Secrets, secret) {
+			secrets = append(secrets, secret)
+			inherited = append(inherited, fmt.Sprintf("secrets[%s]", secret))
+		}
+	}
+	spec.Secrets = append(secrets, spec.Secrets...)
+
+	for _, ref := range defaults.ImagePullSecrets {
+		if !hasPullSecret(spec.ImagePullSecrets, ref.Name) {
+			spec.ImagePullSecrets = append(spec.ImagePullSecrets, ref)
+			inherited = append(inherited, fmt.Sprintf("imagePullSecrets[%s]", ref.Name))
+		}
+	}
+
+	return inherited
+}
+
+func hasEnvVar(env []corev1.EnvVar, name string) bool {
+	for _, v := range env {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPullSecret(refs []corev1.LocalObjectReference, name string) bool {
+	for _, ref := range refs {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// appsForEnvironment requeues every App in an Environment's namespace when its defaults change
+func (r *AppReconciler) appsForEnvironment(obj client.Object) []reconcile.Request {
+	apps := &kappv1alpha1.AppList{}
+	if err := r.List(context.Background(), apps, client.InNamespace(obj.GetName())); err != nil {
+		r.Log.Error(err, "Failed to list Apps for Environment", "Environment", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(apps.Items))
+	for _, app := range apps.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: app.Name, Namespace: app.Namespace},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestMergeAppDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      kappv1alpha1.AppSpec
+		defaults  kappv1alpha1.AppDefaults
+		want      kappv1alpha1.AppSpec
+		inherited []string
+	}{
+		{name: "no defaults"},
+		{
+			name:      "unset scalars are filled in",
+			defaults:  kappv1alpha1.AppDefaults{Cpu: "500m", Memory: "1Gi", Public: pointer.BoolPtr(false)},
+			want:      kappv1alpha1.AppSpec{Cpu: "500m", Memory: "1Gi", Public: pointer.BoolPtr(false)},
+			inherited: []string{"cpu", "memory", "public"},
+		},
+		{
+			name:     "the App's own values win",
+			spec:     kappv1alpha1.AppSpec{Cpu: "1", DisableMtls: pointer.BoolPtr(false), Resources: &kappv1alpha1.Resources{Profile: "large"}},
+			defaults: kappv1alpha1.AppDefaults{Cpu: "500m", DisableMtls: pointer.BoolPtr(true), Resources: &kappv1alpha1.Resources{Profile: "small"}},
+			want:     kappv1alpha1.AppSpec{Cpu: "1", DisableMtls: pointer.BoolPtr(false), Resources: &kappv1alpha1.Resources{Profile: "large"}},
+		},
+		{
+			name:      "resources are inherited whole",
+			defaults:  kappv1alpha1.AppDefaults{Resources: &kappv1alpha1.Resources{Profile: "small"}},
+			want:      kappv1alpha1.AppSpec{Resources: &kappv1alpha1.Resources{Profile: "small"}},
+			inherited: []string{"resources"},
+		},
+		{
+			name:      "maps are merged key by key",
+			spec:      kappv1alpha1.AppSpec{Labels: map[string]string{"team": "web"}},
+			defaults:  kappv1alpha1.AppDefaults{Labels: map[string]string{"team": "platform", "env": "prod", "cost": "shared"}, NodeSelector: map[string]string{"zone": "a"}},
+			want:      kappv1alpha1.AppSpec{Labels: map[string]string{"team": "web", "env": "prod", "cost": "shared"}, NodeSelector: map[string]string{"zone": "a"}},
+			inherited: []string{"nodeSelector.zone", "labels.cost", "labels.env"},
+		},
+		{
+			name:     "inherited variables come first",
+			spec:     kappv1alpha1.AppSpec{Env: []corev1.EnvVar{{Name: "URL", Value: "$(HOST)/api"}, {Name: "LEVEL", Value: "debug"}}},
+			defaults: kappv1alpha1.AppDefaults{Env: []corev1.EnvVar{{Name: "HOST", Value: "db"}, {Name: "LEVEL", Value: "info"}}},
+			want: kappv1alpha1.AppSpec{Env: []corev1.EnvVar{
+				{Name: "HOST", Value: "db"},
+				{Name: "URL", Value: "$(HOST)/api"},
+				{Name: "LEVEL", Value: "debug"},
+			}},
+			inherited: []string{"env[HOST]"},
+		},
+		{
+			name:      "secrets and pull secrets are merged",
+			spec:      kappv1alpha1.AppSpec{Secrets: []string{"api"}, ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}}},
+			defaults:  kappv1alpha1.AppDefaults{Secrets: []string{"db", "api"}, ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}},
+			want:      kappv1alpha1.AppSpec{Secrets: []string{"db", "api"}, ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}},
+			inherited: []string{"secrets[db]", "imagePullSecrets[mirror]"},
+		},
+		{
+			name:      "workers stay private",
+			spec:      kappv1alpha1.AppSpec{Worker: pointer.BoolPtr(true)},
+			defaults:  kappv1alpha1.AppDefaults{Public: pointer.BoolPtr(true), DisableSidecar: pointer.BoolPtr(true)},
+			want:      kappv1alpha1.AppSpec{Worker: pointer.BoolPtr(true), DisableSidecar: pointer.BoolPtr(true)},
+			inherited: []string{"disableSidecar"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			inherited := mergeAppDefaults(&spec, &tt.defaults)
+			if !reflect.DeepEqual(inherited, tt.inherited) {
+				t.Errorf("inherited = %v, want %v", inherited, tt.inherited)
+			}
+			if !reflect.DeepEqual(spec, tt.want) {
+				t.Errorf("spec = %+v, want %+v", spec, tt.want)
+			}
+		})
+	}
+}
+
+// Labels inherited from the Environment must reach the generated resources
+func TestAppLabels(t *testing.T) {
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"owner": "payments", "app": "other"}},
+		Spec:       kappv1alpha1.AppSpec{Labels: map[string]string{"team": "web"}},
+	}
+	mergeAppDefaults(&app.Spec, &kappv1alpha1.AppDefaults{Labels: map[string]string{"env": "prod"}})
+
+	want := map[string]string{"owner": "payments", "team": "web", "env": "prod", "app": "web"}
+	if got := appLabels(app); !reflect.DeepEqual(got, want) {
+		t.Errorf("labels = %v, want %v", got, want)
+	}
+}
+
+// Defaults the webhook never saw can still make an App invalid, which stops it from being reconciled
+func TestReconcileInvalidEffectiveSpec(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	env := &kappv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec: kappv1alpha1.EnvironmentSpec{AppDefaults: &kappv1alpha1.AppDefaults{
+			DisableMtls: pointer.BoolPtr(true),
+		}},
+	}
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", Finalizers: []string{appFinalizer}},
+		Spec:       kappv1alpha1.AppSpec{Image: "registry/web", Version: "v1", Access: &kappv1alpha1.Access{Namespaces: []string{"batch"}}},
+	}
+	if err := app.ValidateSpec(); err != nil {
+		t.Fatalf("App invalid before defaults: %v", err)
+	}
+	r := &AppReconciler{Client: newApplyClient(scheme, env, app), Log: ctrl.Log, Scheme: scheme}
+
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(app)})
+	if err != nil || res.Requeue || res.RequeueAfter != 0 {
+		t.Fatalf("Reconcile = %+v, %v, want no retry", res, err)
+	}
+
+	got := &kappv1alpha1.App{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(app), got); err != nil {
+		t.Fatal(err)
+	}
+	degraded := meta.FindStatusCondition(got.Status.Conditions, kappv1alpha1.ConditionDegraded)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != "InvalidSpec" {
+		t.Errorf("Degraded condition %+v, want InvalidSpec", degraded)
+	}
+	if got.Status.LastError == "" {
+		t.Error("no last error recorded")
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(app), &appsv1.Deployment{}); !errors.IsNotFound(err) {
+		t.Errorf("Deployment lookup returned %v, want none created", err)
+	}
+}
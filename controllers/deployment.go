@@ -1,82 +1,101 @@
 package controllers
 
 import (
-	"context"
 	"fmt"
 	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
-	"reflect"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *AppReconciler) reconcileDeployment(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
-	found := &appsv1.Deployment{}
-	desired := r.deployment(app)
-	err := controllerutil.SetControllerReference(app, desired, r.Scheme)
-	if err != nil {
-		return ctrl.Result{}, err
+// deploymentGenerator produces the rolling Deployment running the App
+type deploymentGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g deploymentGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g deploymentGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if workloadKind(app) != kappv1alpha1.WorkloadDeployment {
+		return nil, nil
+	}
+
+	// Blue/green Apps run one deployment per color instead, see reconcileBlueGreen
+	if blueGreenEnabled(app) {
+		return nil, errSkipResource
 	}
 
-	err = r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, found)
+	dep, err := g.r.deployment(app)
 	if err != nil {
-		if errors.IsNotFound(err) {
-			if err = r.Create(ctx, desired); err != nil {
-				return ctrl.Result{}, err
-			}
-			r.Log.Info("Created new deployment", "Name", app.Name, "Namespace", app.Namespace)
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			return ctrl.Result{}, err
-		}
+		return nil, err
 	}
 
-	if !reflect.DeepEqual(found.Status, app.Status.DeploymentStatus) {
-		found.Status.DeepCopyInto(&app.Status.DeploymentStatus)
-		if err := r.Status().Update(ctx, app); err != nil {
-			return ctrl.Result{}, err
-		}
-		r.Log.Info("Updating Status deployment", "Name", app.Name, "Namespace", app.Namespace)
+	// Leave the replica count to the HorizontalPodAutoscaler while it is active, see handOverReplicas
+	if autoscalerActive(app) {
+		dep.Spec.Replicas = nil
 	}
+	return dep, nil
+}
 
-	if !r.deploymentEquality(desired, found) {
-		desired.DeepCopyInto(found)
-		r.Log.Info("Updating deployment", "Name", app.Name, "Namespace", app.Namespace)
-		if err := r.Update(ctx, found); err != nil {
-			return ctrl.Result{Requeue: true}, err
-		}
+// ready records the deployment's status on the App and waits for the rollout to finish
+func (g deploymentGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	dep := obj.(*appsv1.Deployment)
+	clearWorkloadStatus(app)
+	app.Status.Deployment = dep.Status.DeepCopy()
+	if !deploymentRolledOut(dep) {
+		return false, "Waiting for deployment rollout to finish"
 	}
+	return true, ""
+}
 
-	return ctrl.Result{}, nil
+// deploymentRolledOut reports whether the deployment controller has observed the latest spec
+// and every desired replica is updated and available
+func deploymentRolledOut(dep *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	return dep.Status.ObservedGeneration >= dep.Generation &&
+		dep.Status.UpdatedReplicas >= replicas &&
+		dep.Status.AvailableReplicas >= replicas
 }
 
-func (r *AppReconciler) deployment(app *kappv1alpha1.App) *appsv1.Deployment {
+func (r *AppReconciler) deployment(app *kappv1alpha1.App) (*appsv1.Deployment, error) {
 
 	maxUnavailable := 1
-	if app.Spec.Instances != nil && *app.Spec.Instances == 1 {
+	if minInstances(app) == 1 {
 		maxUnavailable = 0
 	}
-	var podAnnotations interface{}
-	if app.Spec.Annotations != nil {
-		podAnnotations = runtime.DeepCopyJSONValue(app.Spec.Annotations)
-	} else {
-		podAnnotations = make(map[string]string)
+	strategy := appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: int32(maxUnavailable)},
+			MaxSurge:       &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+		},
+	}
+	// A new pod can't attach a volume the old one still holds on another node
+	if mountsSingleNodeClaim(app) {
+		strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	}
+	replicas := budgetInstances(app, minInstances(app))
+	podAnnotations := make(map[string]string)
+	for k, v := range app.Spec.Annotations {
+		podAnnotations[k] = v
 	}
 
-	podAnnotations.(map[string]string)["sidecar.istio.io/rewriteAppHTTPProbers"] = "true"
-	podAnnotations.(map[string]string)["seccomp.security.alpha.kubernetes.io/pod"] = "runtime/default"
-	podAnnotations.(map[string]string)["cluster-autoscaler.kubernetes.io/safe-to-evict"] = "true"
+	podAnnotations["sidecar.istio.io/rewriteAppHTTPProbers"] = "true"
+	podAnnotations["seccomp.security.alpha.kubernetes.io/pod"] = "runtime/default"
+	podAnnotations["cluster-autoscaler.kubernetes.io/safe-to-evict"] = "true"
 
-	if app.Spec.DisableSidecar != nil && *app.Spec.DisableSidecar == false {
-		podAnnotations.(map[string]string)["sidecar.istio.io/inject"] = "false"
+	if app.Spec.DisableSidecar != nil && *app.Spec.DisableSidecar {
+		podAnnotations["sidecar.istio.io/inject"] = "false"
 	}
 	var matchExpressions []metav1.LabelSelectorRequirement
 	if app.Spec.NodeSelector != nil {
@@ -89,22 +108,27 @@ func (r *AppReconciler) deployment(app *kappv1alpha1.App) *appsv1.Deployment {
 		}
 	}
 
-	var envFrom []corev1.EnvFromSource
-	if app.Spec.Secrets != nil {
-		for _, secret := range app.Spec.Secrets {
-			envFrom = append(envFrom, corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{
-				LocalObjectReference: corev1.LocalObjectReference{
-					Name: secret,
-				},
-			}})
-		}
+	labels := appLabels(app)
+
+	podLabels := make(map[string]string)
+	for k, v := range labels {
+		podLabels[k] = v
 	}
+	podLabels[trackLabel] = trackStable
 
-	labels := make(map[string]string)
-	for k, v := range app.Labels {
-		labels[k] = v
+	volumes, volumeMounts := podVolumes(app)
+	inits, err := initContainers(app, imageName(app), volumeMounts)
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := sidecarContainers(app)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := app.Spec.ResourceRequirements()
+	if err != nil {
+		return nil, err
 	}
-	labels["app"] = app.Name
 
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -114,14 +138,11 @@ func (r *AppReconciler) deployment(app *kappv1alpha1.App) *appsv1.Deployment {
 			Annotations: app.Spec.Annotations,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: app.Spec.Instances,
-			Strategy: appsv1.DeploymentStrategy{
-				Type: appsv1.RollingUpdateDeploymentStrategyType,
-				RollingUpdate: &appsv1.RollingUpdateDeployment{
-					MaxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: int32(maxUnavailable)},
-					MaxSurge:       &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
-				},
-			},
+			Replicas: &replicas,
+			Strategy: strategy,
+			// The selector predates release tracks and is immutable, so it keeps matching a canary's
+			// pods as well. The deployment controller only manages ReplicaSets it owns, but anything
+			// going by this selector, such as the HorizontalPodAutoscaler, sees the canary's pods too.
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": app.Name,
@@ -131,14 +152,15 @@ func (r *AppReconciler) deployment(app *kappv1alpha1.App) *appsv1.Deployment {
 				ObjectMeta: metav1.ObjectMeta{
 					Name:        app.Name,
 					Namespace:   app.Namespace,
-					Labels:      labels,
-					Annotations: podAnnotations.(map[string]string),
+					Labels:      podLabels,
+					Annotations: podAnnotations,
 				},
 				Spec: corev1.PodSpec{
 					SecurityContext: &corev1.PodSecurityContext{
 						FSGroup: pointer.Int64Ptr(1000),
 					},
 					ServiceAccountName: app.Name,
+					ImagePullSecrets:   app.Spec.ImagePullSecrets,
 					Affinity: &corev1.Affinity{
 						PodAntiAffinity: &corev1.PodAntiAffinity{
 							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
@@ -151,250 +173,213 @@ func (r *AppReconciler) deployment(app *kappv1alpha1.App) *appsv1.Deployment {
 							},
 						},
 					},
-					NodeSelector: app.Spec.NodeSelector,
-					Containers: []corev1.Container{
+					NodeSelector:   app.Spec.NodeSelector,
+					Volumes:        volumes,
+					InitContainers: inits,
+					Containers: append([]corev1.Container{
 						{
 							Name:            app.Name,
 							Image:           imageName(app),
 							Env:             app.Spec.Env,
-							EnvFrom:         envFrom,
+							EnvFrom:         secretEnvFrom(app.Spec.Secrets),
+							VolumeMounts:    volumeMounts,
 							ImagePullPolicy: corev1.PullAlways,
-							ReadinessProbe:  probe(app, 10, 12),
-							LivenessProbe:   probe(app, 120, 1),
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: *app.Spec.Port,
-									Protocol:      "TCP",
-								},
-							},
-							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse(app.Spec.Cpu),
-									corev1.ResourceMemory: resource.MustParse(app.Spec.Memory),
-								},
-								Limits: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse(app.Spec.Cpu),
-									corev1.ResourceMemory: resource.MustParse(app.Spec.Memory),
-								},
-							},
-							SecurityContext: &corev1.SecurityContext{
-								Capabilities: &corev1.Capabilities{
-									Drop: []corev1.Capability{
-										"ALL",
-									},
-								},
-								Privileged:               pointer.BoolPtr(false),
-								RunAsUser:                pointer.Int64Ptr(1000),
-								RunAsGroup:               pointer.Int64Ptr(1000),
-								RunAsNonRoot:             pointer.BoolPtr(true),
-								ReadOnlyRootFilesystem:   pointer.BoolPtr(false),
-								AllowPrivilegeEscalation: pointer.BoolPtr(false),
-							},
+							ReadinessProbe:  readinessProbe(app),
+							LivenessProbe:   livenessProbe(app),
+							StartupProbe:    startupProbe(app),
+							Ports:           containerPorts(app),
+							Resources:       resources,
+							SecurityContext: containerSecurityContext(app),
 						},
-					},
+					}, sidecars...),
 				},
 			},
 		},
-	}
+	}, nil
 }
 
-func imageName(app *kappv1alpha1.App) string {
-	image := app.Spec.Image
-	if app.Spec.Version != "" {
-		image = fmt.Sprintf("%s:%s", image, app.Spec.Version)
-	} else if app.Spec.ImageDigest != "" {
-		image = fmt.Sprintf("%s@%s", image, app.Spec.ImageDigest)
-	} else {
-		image = fmt.Sprintf("%s:latest", image)
-	}
-
-	return image
-}
-
-func probe(app *kappv1alpha1.App, initialDelay int, failureThreshold int) *corev1.Probe {
-	port := intstr.FromInt(int(*app.Spec.Port))
-	if app.Spec.HealthCheckType == "tcp" {
-		return &corev1.Probe{
-			FailureThreshold:    int32(failureThreshold),
-			PeriodSeconds:       10,
-			SuccessThreshold:    1,
-			TimeoutSeconds:      1,
-			InitialDelaySeconds: int32(initialDelay),
-			Handler: corev1.Handler{
-				TCPSocket: &corev1.TCPSocketAction{
-					Port: port,
-				},
-			},
+// sidecarContainers returns the App's sidecars, with the same pull policy and security context as the application container
+func sidecarContainers(app *kappv1alpha1.App) ([]corev1.Container, error) {
+	var containers []corev1.Container
+	for _, sidecar := range app.Spec.Sidecars {
+		cpu, memory := sidecar.Cpu, sidecar.Memory
+		if cpu == "" {
+			cpu = "50m"
 		}
-	} else {
-		return &corev1.Probe{
-			FailureThreshold:    int32(failureThreshold),
-			PeriodSeconds:       10,
-			SuccessThreshold:    1,
-			TimeoutSeconds:      10,
-			InitialDelaySeconds: int32(initialDelay),
-			Handler: corev1.Handler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Port:   port,
-					Path:   app.Spec.HealthCheckEndpoint,
-					Scheme: "HTTP",
-				},
-			},
+		if memory == "" {
+			memory = "64Mi"
+		}
+		resources, err := containerResources(cpu, memory)
+		if err != nil {
+			return nil, fmt.Errorf("sidecar %s: %w", sidecar.Name, err)
 		}
-	}
-}
 
-func (r *AppReconciler) logDeploymentInequality(dep *appsv1.Deployment, propertyName string, desired, actual interface{}) {
-	r.logDifference(desired, actual, propertyName, dep.Name, dep.Namespace, dep.TypeMeta)
+		containers = append(containers, corev1.Container{
+			Name:            sidecar.Name,
+			Image:           sidecar.Image,
+			Command:         sidecar.Command,
+			Args:            sidecar.Args,
+			Env:             sidecar.Env,
+			EnvFrom:         secretEnvFrom(sidecar.Secrets),
+			Ports:           sidecar.Ports,
+			VolumeMounts:    tmpVolumeMounts(app),
+			ImagePullPolicy: corev1.PullAlways,
+			ReadinessProbe:  sidecar.ReadinessProbe,
+			LivenessProbe:   sidecar.LivenessProbe,
+			Resources:       resources,
+			SecurityContext: containerSecurityContext(app),
+		})
+	}
+	return containers, nil
 }
 
-func (r *AppReconciler) deploymentEquality(desired *appsv1.Deployment, actual *appsv1.Deployment) bool {
-
-	// Validate all desired labels are present
-	if !mapMatch(desired.Labels, actual.Labels) {
-		r.logDeploymentInequality(desired, "labels", desired.Labels, actual.Labels)
-		return false
-	}
-
-	// Validate all desired annotations are present
-	if !mapMatch(desired.Annotations, actual.Annotations) {
-		r.logDeploymentInequality(desired, "annotations", desired.Annotations, desired.Labels)
-		return false
-	}
-
-	// Check if Replicas match
-	if *actual.Spec.Replicas != *desired.Spec.Replicas {
-		r.logDeploymentInequality(desired, "replicas", desired.Spec.Replicas, actual.Spec.Replicas)
-		return false
-	}
-
-	// Ensure Pod Labels are set correctly
-	if !mapMatch(desired.Spec.Template.Labels, actual.Spec.Template.Labels) {
-		r.logDeploymentInequality(desired, "podLabels", desired.Spec.Template.Labels, actual.Spec.Template.Labels)
-		return false
-	}
-
-	// Ensure Pod Annotations are set correctly
-	if !mapMatch(desired.Spec.Template.Annotations, actual.Spec.Template.Annotations) {
-		r.logDeploymentInequality(desired, "podAnnotations", desired.Spec.Template.Annotations, actual.Spec.Template.Annotations)
-		return false
-	}
-
-	aps := actual.Spec.Template.Spec
-	dps := desired.Spec.Template.Spec
-
-	// Ensure Security Context is correct
-	if *aps.SecurityContext.FSGroup != *dps.SecurityContext.FSGroup {
-		r.logDeploymentInequality(desired, "securityContext", dps.SecurityContext, aps.SecurityContext)
-		return false
-	}
-
-	// Ensure Service Account is correct
-	if aps.ServiceAccountName != dps.ServiceAccountName {
-		r.logDeploymentInequality(desired, "serviceAccountName", dps.ServiceAccountName, aps.ServiceAccountName)
-		return false
-	}
-
-	// Ensure Affinity is correct
-	if !reflect.DeepEqual(aps.Affinity, dps.Affinity) {
-		r.logDeploymentInequality(desired, "affinity", dps.Affinity, aps.Affinity)
-		return false
-	}
-
-	// Ensure Node Selector is correct
-	if !reflect.DeepEqual(aps.NodeSelector, dps.NodeSelector) {
-		r.logDeploymentInequality(desired, "nodeSelector", dps.NodeSelector, aps.NodeSelector)
-		return false
-	}
-
-	acs := aps.Containers[0]
-	dcs := dps.Containers[0]
-
-	// Ensure Name is correct
-	if acs.Name != dcs.Name {
-		r.logDeploymentInequality(desired, "containerName", dcs.Name, acs.Name)
-		return false
-	}
-
-	// Ensure Image is correct
-	if acs.Image != dcs.Image {
-		r.logDeploymentInequality(desired, "image", dcs.Image, acs.Image)
-		return false
-	}
-
-	// Ensure env is correct
-	for _, dv := range dcs.Env {
-		found := false
-		for _, av := range acs.Env {
-			if av.Name == dv.Name && av.Value == dv.Value {
-				found = true
-				break
-			}
+// initContainers returns the App's init containers, defaulting to image and to the application
+// container's environment, config, resources and security context
+func initContainers(app *kappv1alpha1.App, image string, volumeMounts []corev1.VolumeMount) ([]corev1.Container, error) {
+	var containers []corev1.Container
+	for _, c := range app.Spec.InitContainers {
+		containerImage := image
+		if c.Image != "" {
+			containerImage = c.Image
 		}
-		if found == false {
-			r.logDeploymentInequality(desired, "containerEnv", dcs.Env, acs.Env)
-			return false
+		resources, err := app.Spec.ResourceRequirements()
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	// Ensure envFrom is correct
-	for _, dv := range dcs.EnvFrom {
-		found := false
-		for _, av := range acs.EnvFrom {
-			if dv.SecretRef != nil {
-				if av.SecretRef != nil && av.SecretRef.Name == dv.SecretRef.Name {
-					found = true
-					break
-				}
+		for _, override := range []struct {
+			name  corev1.ResourceName
+			value string
+		}{
+			{corev1.ResourceCPU, c.Cpu},
+			{corev1.ResourceMemory, c.Memory},
+		} {
+			if override.value == "" {
+				continue
 			}
-			if dv.ConfigMapRef != nil {
-				if av.ConfigMapRef != nil && av.ConfigMapRef.Name == dv.ConfigMapRef.Name {
-					found = true
-					break
-				}
+			q, err := resource.ParseQuantity(override.value)
+			if err != nil {
+				return nil, fmt.Errorf("init container %s: invalid %s %q: %w", c.Name, override.name, override.value, err)
 			}
+			resources.Requests[override.name] = q
+			resources.Limits[override.name] = q
 		}
-		if found == false {
-			r.logDeploymentInequality(desired, "containerEnvFrom", dcs.EnvFrom, acs.EnvFrom)
-			return false
+
+		var env []corev1.EnvVar
+		env = append(env, app.Spec.Env...)
+		env = append(env, c.Env...)
+
+		var secrets []string
+		secrets = append(secrets, app.Spec.Secrets...)
+		secrets = append(secrets, c.Secrets...)
+
+		containers = append(containers, corev1.Container{
+			Name:            c.Name,
+			Image:           containerImage,
+			Command:         c.Command,
+			Args:            c.Args,
+			Env:             env,
+			EnvFrom:         secretEnvFrom(secrets),
+			VolumeMounts:    volumeMounts,
+			ImagePullPolicy: corev1.PullAlways,
+			Resources:       resources,
+			SecurityContext: containerSecurityContext(app),
+		})
+	}
+	return containers, nil
+}
+
+// setVersion runs version of the App's image in the application container and in the
+// init containers that default to it
+func setVersion(dep *appsv1.Deployment, app *kappv1alpha1.App, version string) {
+	image := imageNameForVersion(app, version)
+	spec := &dep.Spec.Template.Spec
+	spec.Containers[0].Image = image
+	for i, c := range app.Spec.InitContainers {
+		if c.Image == "" {
+			spec.InitContainers[i].Image = image
 		}
 	}
+}
 
-	// Ensure ImagePullPolicy is correct
-	if acs.ImagePullPolicy != dcs.ImagePullPolicy {
-		r.logDeploymentInequality(desired, "imagePullPolicy", dcs.ImagePullPolicy, acs.ImagePullPolicy)
-		return false
+// secretEnvFrom exposes every key of each secret as an environment variable
+func secretEnvFrom(secrets []string) []corev1.EnvFromSource {
+	var envFrom []corev1.EnvFromSource
+	for _, secret := range secrets {
+		envFrom = append(envFrom, corev1.EnvFromSource{SecretRef: &corev1.SecretEnvSource{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: secret,
+			},
+		}})
 	}
+	return envFrom
+}
 
-	// Ensure ReadinessProbe is correct
-	if !reflect.DeepEqual(*acs.ReadinessProbe, *dcs.ReadinessProbe) {
-		r.logDeploymentInequality(desired, "readinessProbe", dcs.ReadinessProbe, acs.ReadinessProbe)
-		return false
+// containerResources requests and limits a container to the same cpu and memory
+func containerResources(cpu, memory string) (corev1.ResourceRequirements, error) {
+	cpuQuantity, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid cpu %q: %w", cpu, err)
 	}
-
-	// Ensure LivenessProbe is correct
-	if !reflect.DeepEqual(*acs.LivenessProbe, *dcs.LivenessProbe) {
-		r.logDeploymentInequality(desired, "livenessProbe", dcs.LivenessProbe, acs.LivenessProbe)
-		return false
+	memoryQuantity, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("invalid memory %q: %w", memory, err)
 	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    cpuQuantity,
+			corev1.ResourceMemory: memoryQuantity,
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    cpuQuantity.DeepCopy(),
+			corev1.ResourceMemory: memoryQuantity.DeepCopy(),
+		},
+	}, nil
+}
 
-	// Ensure Ports are correct
-	if !reflect.DeepEqual(acs.Ports, dcs.Ports) {
-		r.logDeploymentInequality(desired, "ports", dcs.Ports, acs.Ports)
-		return false
+// containerSecurityContext runs a container unprivileged as a non-root user with every capability dropped
+func containerSecurityContext(app *kappv1alpha1.App) *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{
+				"ALL",
+			},
+		},
+		Privileged:               pointer.BoolPtr(false),
+		RunAsUser:                pointer.Int64Ptr(1000),
+		RunAsGroup:               pointer.Int64Ptr(1000),
+		RunAsNonRoot:             pointer.BoolPtr(true),
+		ReadOnlyRootFilesystem:   pointer.BoolPtr(readOnlyRootFilesystem(app)),
+		AllowPrivilegeEscalation: pointer.BoolPtr(false),
 	}
+}
 
-	// Ensure Resource Quotas are correct
-	if !reflect.DeepEqual(acs.Resources, dcs.Resources) {
-		r.logDeploymentInequality(desired, "resources", dcs.Resources, acs.Resources)
-		return false
-	}
+func imageName(app *kappv1alpha1.App) string {
+	return imageNameForVersion(app, app.Spec.Version)
+}
 
-	// Ensure Container Security Context is correct
-	if !reflect.DeepEqual(acs.SecurityContext, dcs.SecurityContext) {
-		r.logDeploymentInequality(desired, "containerSecurityContext", dcs.SecurityContext, acs.SecurityContext)
-		return false
+func imageNameForVersion(app *kappv1alpha1.App, version string) string {
+	image := app.Spec.Image
+	if version != "" {
+		image = fmt.Sprintf("%s:%s", image, version)
+	} else if app.Spec.ImageDigest != "" {
+		image = fmt.Sprintf("%s@%s", image, app.Spec.ImageDigest)
+	} else {
+		image = fmt.Sprintf("%s:latest", image)
 	}
 
-	return true
+	return image
+}
+
+// containerPorts returns the application container's port, if it listens on one
+func containerPorts(app *kappv1alpha1.App) []corev1.ContainerPort {
+	port := appPort(app)
+	if port == nil {
+		return nil
+	}
+	return []corev1.ContainerPort{
+		{
+			ContainerPort: *port,
+			Protocol:      "TCP",
+		},
+	}
 }
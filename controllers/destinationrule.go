@@ -1,48 +1,28 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
 	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
 	"istio.io/api/networking/v1alpha3"
 	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"reflect"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *AppReconciler) reconcileDestinationRule(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
-	found := &istio.DestinationRule{}
-	desired := r.destinationRule(app)
-	err := controllerutil.SetControllerReference(app, desired, r.Scheme)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
+// destinationRuleGenerator produces the DestinationRule defining the App's traffic policy and subsets
+type destinationRuleGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
 
-	err = r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, found)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			if err = r.Create(ctx, desired); err != nil {
-				return ctrl.Result{}, err
-			}
-			r.Log.Info("Created new DestinationRule", "Name", app.Name, "Namespace", app.Namespace)
-			return ctrl.Result{Requeue: true}, nil
-		}
-	} else {
-		return ctrl.Result{}, err
-	}
+func (g destinationRuleGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &istio.DestinationRule{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
 
-	if !reflect.DeepEqual(desired.Spec, found.Spec) {
-		desired.DeepCopyInto(found)
-		r.Log.Info("Updating DestinationRule", "Name", app.Name, "Namespace", app.Namespace)
-		if err := r.Update(ctx, found); err != nil {
-			return ctrl.Result{Requeue: true}, err
-		}
+func (g destinationRuleGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !receivesTraffic(app) {
+		return nil, nil
 	}
-	return ctrl.Result{}, nil
+	return g.r.destinationRule(app), nil
 }
 
 func (r *AppReconciler) destinationRule(app *kappv1alpha1.App) *istio.DestinationRule {
@@ -55,10 +35,10 @@ func (r *AppReconciler) destinationRule(app *kappv1alpha1.App) *istio.Destinatio
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.Name,
 			Namespace: app.Namespace,
-			Labels:    app.Labels,
+			Labels:    appLabels(app),
 		},
 		Spec: v1alpha3.DestinationRule{
-			Host: fmt.Sprintf("%s.%s.svc.cluster.local", app.Name, app.Namespace),
+			Host: serviceHost(app),
 			TrafficPolicy: &v1alpha3.TrafficPolicy{
 				Tls: &v1alpha3.ClientTLSSettings{
 					Mode: tlsMode,
@@ -69,6 +49,24 @@ func (r *AppReconciler) destinationRule(app *kappv1alpha1.App) *istio.Destinatio
 					},
 				},
 			},
+			Subsets: []*v1alpha3.Subset{
+				{
+					Name:   trackStable,
+					Labels: map[string]string{trackLabel: trackStable},
+				},
+				{
+					Name:   trackCanary,
+					Labels: map[string]string{trackLabel: trackCanary},
+				},
+				{
+					Name:   trackBlue,
+					Labels: map[string]string{trackLabel: trackBlue},
+				},
+				{
+					Name:   trackGreen,
+					Labels: map[string]string{trackLabel: trackGreen},
+				},
+			},
 		},
 	}
 }
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// disruptionBudgetGenerator produces the PodDisruptionBudget for Apps running more than one instance
+type disruptionBudgetGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g disruptionBudgetGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &policyv1beta1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g disruptionBudgetGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	// A budget on a single instance would block node drains, so remove any existing one.
+	// Batch work is retried instead, so it gets no budget either.
+	if minInstances(app) <= 1 || batchWorkload(app) {
+		return nil, nil
+	}
+	return g.r.disruptionBudget(app), nil
+}
+
+func (r *AppReconciler) disruptionBudget(app *kappv1alpha1.App) *policyv1beta1.PodDisruptionBudget {
+	labels := appLabels(app)
+
+	// Mirror the rolling update's maxUnavailable unless overridden. Only the pods receiving live
+	// traffic are budgeted, so canary and preview pods don't count towards the available ones.
+	maxUnavailable := intstr.FromInt(1)
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		MaxUnavailable: &maxUnavailable,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":      app.Name,
+				trackLabel: liveTrack(app),
+			},
+		},
+	}
+	if db := app.Spec.DisruptionBudget; db != nil {
+		if db.MinAvailable != nil {
+			spec.MinAvailable = db.MinAvailable
+			spec.MaxUnavailable = nil
+		} else if db.MaxUnavailable != nil {
+			spec.MaxUnavailable = db.MaxUnavailable
+		}
+	}
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: spec,
+	}
+}
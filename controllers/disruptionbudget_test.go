@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+// Only pods receiving live traffic count towards the disruption budget
+func TestDisruptionBudgetSelector(t *testing.T) {
+	r := &AppReconciler{}
+	blueGreen := func(color string, live bool) *kappv1alpha1.App {
+		return &kappv1alpha1.App{
+			ObjectMeta: metav1.ObjectMeta{Name: "web"},
+			Spec:       kappv1alpha1.AppSpec{BlueGreen: &kappv1alpha1.BlueGreen{}},
+			Status:     kappv1alpha1.AppStatus{BlueGreen: &kappv1alpha1.BlueGreenStatus{ActiveColor: color, Live: live}},
+		}
+	}
+	canary := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec:       kappv1alpha1.AppSpec{Canary: &kappv1alpha1.Canary{Version: "v2"}},
+	}
+
+	for _, tt := range []struct {
+		app   *kappv1alpha1.App
+		track string
+	}{
+		{app: canary, track: trackStable},
+		{app: blueGreen(trackGreen, true), track: trackGreen},
+		// Until the active color is live the rolling deployment still serves traffic
+		{app: blueGreen(trackBlue, false), track: trackStable},
+	} {
+		want := map[string]string{"app": "web", trackLabel: tt.track}
+		if got := r.disruptionBudget(tt.app).Spec.Selector.MatchLabels; !reflect.DeepEqual(got, want) {
+			t.Errorf("selector %v, want %v", got, want)
+		}
+	}
+}
@@ -18,8 +18,13 @@ package controllers
 
 import (
 	"context"
+	"reflect"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,6 +42,12 @@ type EnvironmentReconciler struct {
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=environments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=environments/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kapp.kappa.io,resources=environments/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=resourcequotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=limitranges,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -49,14 +60,60 @@ type EnvironmentReconciler struct {
 func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = r.Log.WithValues("environment", req.NamespacedName)
 
-	// your logic here
+	env := &kappv1alpha1.Environment{}
+	err := r.Get(ctx, req.NamespacedName, env)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	// Status is written after every pass, including those cut short by an error. Each step
+	// records its own resource, so what earlier passes recorded is kept until then.
+	status := env.Status.DeepCopy()
+	res, err := r.runSteps(ctx, env, status)
+
+	if !reflect.DeepEqual(&env.Status, status) {
+		env.Status = *status
+		r.Log.Info("Updating Status environment", "Name", env.Name)
+		if statusErr := r.Status().Update(ctx, env); statusErr != nil && err == nil {
+			return ctrl.Result{}, statusErr
+		}
+	}
+
+	return res, err
+}
+
+// runSteps provisions each of the Environment's resources in order, stopping at the first failure.
+// A requeue is requested when any step asks for one.
+func (r *EnvironmentReconciler) runSteps(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	steps := []func(context.Context, *kappv1alpha1.Environment, *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error){
+		r.reconcileNamespace,
+		r.reconcileResourceQuota,
+		r.reconcileLimitRange,
+		r.reconcileRoleBindings,
+		r.reconcileNetworkPolicy,
+	}
+	result := ctrl.Result{}
+	for _, step := range steps {
+		res, err := step(ctx, env, status)
+		if err != nil {
+			return res, err
+		}
+		result.Requeue = result.Requeue || res.Requeue
+	}
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kappv1alpha1.Environment{}).
+		Owns(&corev1.Namespace{}).
+		Owns(&corev1.ResourceQuota{}).
+		Owns(&corev1.LimitRange{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&networkingv1.NetworkPolicy{}).
 		Complete(r)
 }
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+// failingLimitRanges fails every LimitRange create while failing is set
+type failingLimitRanges struct {
+	client.Client
+	failing bool
+}
+
+func (c *failingLimitRanges) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*corev1.LimitRange); ok && c.failing {
+		return fmt.Errorf("limit ranges unavailable")
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestEnvironmentStatus(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	env := &kappv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec:       kappv1alpha1.EnvironmentSpec{Teams: []kappv1alpha1.TeamAccess{{Name: "web", Groups: []string{"web-devs"}}}},
+	}
+	c := &failingLimitRanges{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(env).Build(), failing: true}
+	r := &EnvironmentReconciler{Client: c, Log: ctrl.Log, Scheme: scheme}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(env)}
+	status := func() kappv1alpha1.EnvironmentStatus {
+		t.Helper()
+		got := &kappv1alpha1.Environment{}
+		if err := r.Get(ctx, req.NamespacedName, got); err != nil {
+			t.Fatal(err)
+		}
+		return got.Status
+	}
+
+	// What was provisioned before the failure is recorded
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Fatal("no error with the LimitRange failing")
+	}
+	want := kappv1alpha1.EnvironmentStatus{Namespace: "team", ResourceQuota: "team"}
+	if got := status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("status = %+v after a failed pass, want %+v", got, want)
+	}
+
+	c.failing = false
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	got := status()
+	if got.LimitRange == "" || len(got.RoleBindings) != 1 || got.Namespace != "team" {
+		t.Errorf("status = %+v, want every resource recorded", got)
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	security "istio.io/client-go/pkg/apis/security/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+// appFinalizer holds an App until everything it created has been cleaned up
+const appFinalizer = "kappa.io/finalizer"
+
+// cleanupRequeue is how long to wait before checking on deletions that are still in progress
+const cleanupRequeue = 5 * time.Second
+
+// CleanupHook removes something an App created but cannot own, such as objects in other
+// namespaces or records in external systems. Hooks run in order before owned resources are removed.
+type CleanupHook interface {
+	// Name identifies the hook in logs and status
+	Name() string
+	// Cleanup removes what the hook is responsible for, returning true once nothing is left
+	Cleanup(ctx context.Context, app *kappv1alpha1.App) (bool, error)
+}
+
+// ownedCleanupOrder lists the kinds an App owns in the order they are removed, taking
+// routing down before the workloads behind it
+func ownedCleanupOrder() []client.ObjectList {
+	return []client.ObjectList{
+		&istio.VirtualServiceList{},
+		&istio.DestinationRuleList{},
+		&security.AuthorizationPolicyList{},
+		&corev1.ServiceList{},
+		&autoscalingv2beta2.HorizontalPodAutoscalerList{},
+		&policyv1beta1.PodDisruptionBudgetList{},
+		&appsv1.DeploymentList{},
+		&appsv1.StatefulSetList{},
+		&batchv1beta1.CronJobList{},
+		&batchv1.JobList{},
+		&networkingv1.NetworkPolicyList{},
+		&corev1.PersistentVolumeClaimList{},
+		&corev1.ConfigMapList{},
+		&corev1.ServiceAccountList{},
+	}
+}
+
+// ensureFinalizer adds the cleanup finalizer to an App that does not have it yet
+func (r *AppReconciler) ensureFinalizer(ctx context.Context, app *kappv1alpha1.App) error {
+	if controllerutil.ContainsFinalizer(app, appFinalizer) {
+		return nil
+	}
+	patch := finalizerPatch(app)
+	controllerutil.AddFinalizer(app, appFinalizer)
+	return r.Patch(ctx, app, patch)
+}
+
+// finalizerPatch patches only the finalizers changed on app since the call, failing on a
+// concurrent change instead of overwriting finalizers added by others
+func finalizerPatch(app *kappv1alpha1.App) client.Patch {
+	return client.MergeFromWithOptions(app.DeepCopy(), client.MergeFromWithOptimisticLock{})
+}
+
+// finalize runs the cleanup hooks and then removes owned resources in order, releasing
+// the App once nothing is left. Progress is reported in the Terminating condition.
+func (r *AppReconciler) finalize(ctx context.Context, app *kappv1alpha1.App) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(app, appFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	original := app.Status.DeepCopy()
+	res, done, err := r.cleanup(ctx, app)
+	if err != nil || !done {
+		if statusErr := r.updateStatus(ctx, app, original); statusErr != nil && err == nil {
+			return ctrl.Result{}, statusErr
+		}
+		return res, err
+	}
+
+	r.Log.Info("Cleanup complete, removing finalizer", "Name", app.Name, "Namespace", app.Namespace)
+	patch := finalizerPatch(app)
+	controllerutil.RemoveFinalizer(app, appFinalizer)
+	if err := r.Patch(ctx, app, patch); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *AppReconciler) cleanup(ctx context.Context, app *kappv1alpha1.App) (ctrl.Result, bool, error) {
+	for _, hook := range r.CleanupHooks {
+		done, err := hook.Cleanup(ctx, app)
+		if err != nil {
+			r.setCondition(app, kappv1alpha1.ConditionTerminating, metav1.ConditionTrue, "CleanupFailed",
+				fmt.Sprintf("%s: %v", hook.Name(), err))
+			return ctrl.Result{}, false, err
+		}
+		if !done {
+			r.setCondition(app, kappv1alpha1.ConditionTerminating, metav1.ConditionTrue, "CleanupInProgress",
+				fmt.Sprintf("Waiting for %s cleanup", hook.Name()))
+			return ctrl.Result{RequeueAfter: cleanupRequeue}, false, nil
+		}
+	}
+
+	for _, list := range ownedCleanupOrder() {
+		remaining, err := r.deleteOwned(ctx, app, list)
+		if err != nil {
+			r.setCondition(app, kappv1alpha1.ConditionTerminating, metav1.ConditionTrue, "CleanupFailed", err.Error())
+			return ctrl.Result{}, false, err
+		}
+		if remaining > 0 {
+			kind := fmt.Sprintf("%T", list)
+			r.setCondition(app, kappv1alpha1.ConditionTerminating, metav1.ConditionTrue, "CleanupInProgress",
+				fmt.Sprintf("Waiting for %d %s to be deleted", remaining, kind[1:len(kind)-len("List")]))
+			return ctrl.Result{RequeueAfter: cleanupRequeue}, false, nil
+		}
+	}
+
+	return ctrl.Result{}, true, nil
+}
+
+// deleteOwned deletes every object of the list's kind controlled by the App, returning how many still exist
+func (r *AppReconciler) deleteOwned(ctx context.Context, app *kappv1alpha1.App, list client.ObjectList) (int, error) {
+	if err := r.List(ctx, list, client.InNamespace(app.Namespace)); err != nil {
+		return 0, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return 0, err
+	}
+
+	remaining := 0
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok || !metav1.IsControlledBy(obj, app) {
+			continue
+		}
+		remaining++
+		if obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		r.Log.Info("Deleting owned resource", "Type", fmt.Sprintf("%T", obj), "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+		if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil && !errors.IsNotFound(err) {
+			return 0, err
+		}
+	}
+	return remaining, nil
+}
+
+// LabelledObjectCleanup is a CleanupHook deleting objects of the given kinds, in any namespace,
+// that carry the App's name and namespace labels.
+//
+// kappa never sets kappv1alpha1.AppNameLabel and AppNamespaceLabel itself: whatever creates an
+// object on an App's behalf, such as a pipeline or another controller, is responsible for
+// labelling it. The kinds are only known once the manager is deployed with --cleanup-kinds, so
+// the generated ClusterRole doesn't cover them. The manager's ServiceAccount needs an additional
+// ClusterRole allowing list and delete on each kind, for example for Kind.version.group:
+//
+//   - apiGroups: ["group"]
+//     resources: ["kinds"]
+//     verbs: ["list", "delete"]
+type LabelledObjectCleanup struct {
+	client.Client
+	Kinds []schema.GroupVersionKind
+}
+
+// Name implements CleanupHook
+func (c *LabelledObjectCleanup) Name() string {
+	return "labelled objects"
+}
+
+// Cleanup implements CleanupHook
+func (c *LabelledObjectCleanup) Cleanup(ctx context.Context, app *kappv1alpha1.App) (bool, error) {
+	done := true
+	for _, gvk := range c.Kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		err := c.List(ctx, list, client.MatchingLabels{
+			kappv1alpha1.AppNameLabel:      app.Name,
+			kappv1alpha1.AppNamespaceLabel: app.Namespace,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		for i := range list.Items {
+			done = false
+			obj := &list.Items[i]
+			if obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if err := c.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+		}
+	}
+	return done, nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"testing"
+)
+
+// stubHook is a CleanupHook that reports done once it has been called a number of times
+type stubHook struct {
+	name  string
+	after int
+	calls int
+}
+
+func (h *stubHook) Name() string {
+	return h.name
+}
+
+func (h *stubHook) Cleanup(ctx context.Context, app *kappv1alpha1.App) (bool, error) {
+	h.calls++
+	return h.calls > h.after, nil
+}
+
+func TestFinalize(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	now := metav1.Now()
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{
+		Name:              "web",
+		Namespace:         "team",
+		UID:               "uid",
+		DeletionTimestamp: &now,
+		Finalizers:        []string{appFinalizer},
+	}}
+
+	owned := []client.Object{
+		&istio.VirtualService{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}},
+	}
+	for _, obj := range owned {
+		if err := controllerutil.SetControllerReference(app, obj, scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unowned := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team"}}
+
+	first := &stubHook{name: "dns", after: 1}
+	second := &stubHook{name: "certificates"}
+	r := &AppReconciler{
+		Client:       fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(owned, unowned, app)...).Build(),
+		Log:          ctrl.Log,
+		Scheme:       scheme,
+		CleanupHooks: []CleanupHook{first, second},
+	}
+	exists := func(obj client.Object) bool {
+		err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj.DeepCopyObject().(client.Object))
+		if err != nil && !errors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	finalize := func() ctrl.Result {
+		t.Helper()
+		result, err := r.finalize(ctx, app)
+		if err != nil {
+			t.Fatalf("finalize: %v", err)
+		}
+		return result
+	}
+
+	// Hooks run in order and hold everything else back until they are done
+	result := finalize()
+	if result.RequeueAfter == 0 || second.calls != 0 {
+		t.Fatalf("requeue after %v with the second hook called %d times, want a requeue before it runs", result.RequeueAfter, second.calls)
+	}
+	condition := meta.FindStatusCondition(app.Status.Conditions, kappv1alpha1.ConditionTerminating)
+	if condition == nil || !strings.Contains(condition.Message, "dns") {
+		t.Fatalf("terminating condition %v, want it waiting for dns", condition)
+	}
+	for _, obj := range owned {
+		if !exists(obj) {
+			t.Fatalf("%T deleted while a cleanup hook was in progress", obj)
+		}
+	}
+
+	// Owned resources then go one kind per pass, routing before the workloads behind it
+	for i, obj := range owned {
+		finalize()
+		if exists(obj) {
+			t.Fatalf("pass %d: %T still exists", i, obj)
+		}
+		for _, later := range owned[i+1:] {
+			if !exists(later) {
+				t.Fatalf("pass %d: %T deleted before %T", i, later, obj)
+			}
+		}
+	}
+
+	if result := finalize(); result.RequeueAfter != 0 {
+		t.Fatalf("requeue after %v once everything was deleted", result.RequeueAfter)
+	}
+	if controllerutil.ContainsFinalizer(app, appFinalizer) {
+		t.Error("finalizer kept after cleanup completed")
+	}
+	if !exists(unowned) {
+		t.Error("deployment not controlled by the App deleted")
+	}
+}
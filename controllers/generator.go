@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	"hash/fnv"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// appliedHashAnnotation records a hash of the object kappa last applied, so removed fields are
+// detected as a difference even though the existing object no longer mentions them
+const appliedHashAnnotation = "kappa.io/applied-hash"
+
+// errSkipResource is returned by generator.desired to leave an existing resource untouched,
+// neither applying nor deleting it
+var errSkipResource = errors.New("resource is not managed by the generator right now")
+
+// generator produces one kind of resource managed by an App. Every generator is read, applied,
+// deleted and reported on the same way by reconcileGenerated, so a new managed kind only needs
+// a generator and an entry in the App's reconcile steps.
+type generator interface {
+	// object returns an empty object of the generated kind, named like the resource, to read the existing one into
+	object(app *kappv1alpha1.App) client.Object
+	// desired returns the resource the App should have, or nil when it should not exist
+	desired(app *kappv1alpha1.App) (client.Object, error)
+	// equal reports whether the existing resource already matches desired, so applying can be skipped
+	equal(desired, existing client.Object) bool
+	// ready reports whether the resource is ready, with a message explaining why not. It may record
+	// what it observes on the App's status.
+	ready(app *kappv1alpha1.App, obj client.Object) (bool, string)
+}
+
+// listGenerator is implemented by generators whose resource name changes over time, such as a
+// Job named after its pod template. Every other resource of the kind controlled by the App is removed.
+type listGenerator interface {
+	generator
+	// list returns an empty list of the generated kind
+	list() client.ObjectList
+}
+
+// generatorDefaults provides the equality and readiness most generators share. Generators embed
+// it and override what differs for their kind.
+type generatorDefaults struct{}
+
+// equal reports whether desired was the last object applied and every field it sets still holds
+// that value, ignoring fields set by the API server or other actors
+func (generatorDefaults) equal(desired, existing client.Object) bool {
+	if desired.GetAnnotations()[appliedHashAnnotation] != existing.GetAnnotations()[appliedHashAnnotation] {
+		return false
+	}
+	return equality.Semantic.DeepDerivative(desired, existing)
+}
+
+// ready reports resources as ready as soon as they exist
+func (generatorDefaults) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	return true, ""
+}
+
+// removedGenerator removes a resource under a name the App no longer uses, such as the rolling
+// Deployment once blue/green colors have taken its place
+type removedGenerator struct {
+	generatorDefaults
+	obj client.Object
+}
+
+func (g removedGenerator) object(app *kappv1alpha1.App) client.Object {
+	return g.obj
+}
+
+func (g removedGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	return nil, nil
+}
+
+// reconcileGenerated brings the resource produced by g in line with the App, returning whether it is ready
+func (r *AppReconciler) reconcileGenerated(ctx context.Context, app *kappv1alpha1.App, g generator) (bool, string, error) {
+	existing := g.object(app)
+	err := r.Get(ctx, client.ObjectKeyFromObject(existing), existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, "", err
+	}
+	exists := err == nil
+	kind := fmt.Sprintf("%T", existing)[1:]
+
+	desired, err := g.desired(app)
+	if errors.Is(err, errSkipResource) {
+		return true, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	if lg, ok := g.(listGenerator); ok {
+		keep := ""
+		if desired != nil {
+			keep = desired.GetName()
+		}
+		if err := r.deleteStale(ctx, app, lg.list(), keep); err != nil {
+			return false, "", err
+		}
+	}
+
+	// Remove a previously created resource the App no longer needs
+	if desired == nil {
+		if exists && metav1.IsControlledBy(existing, app) {
+			if err := r.deleteGenerated(ctx, existing); err != nil {
+				return false, "", err
+			}
+		}
+		return true, "", nil
+	}
+
+	if err := controllerutil.SetControllerReference(app, desired, r.Scheme); err != nil {
+		return false, "", err
+	}
+	if err := setAppliedHash(desired); err != nil {
+		return false, "", err
+	}
+
+	if !exists || !g.equal(desired, existing) {
+		if exists {
+			if err := r.handOverReplicas(ctx, desired, existing); err != nil {
+				return false, "", err
+			}
+		}
+		r.Log.Info("Applying resource", "Type", kind, "Name", desired.GetName(), "Namespace", desired.GetNamespace())
+		if err := r.apply(ctx, app, desired); err != nil {
+			return false, "", err
+		}
+		existing = desired
+	}
+
+	ready, message := g.ready(app, existing)
+	return ready, message, nil
+}
+
+// deleteStale deletes every resource in list's kind controlled by the App, except the one named keep
+func (r *AppReconciler) deleteStale(ctx context.Context, app *kappv1alpha1.App, list client.ObjectList, keep string) error {
+	if err := r.List(ctx, list, client.InNamespace(app.Namespace)); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok || obj.GetName() == keep || !metav1.IsControlledBy(obj, app) || obj.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if err := r.deleteGenerated(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteGenerated deletes a resource along with anything it created, such as a Job's pods
+func (r *AppReconciler) deleteGenerated(ctx context.Context, obj client.Object) error {
+	r.Log.Info("Deleting resource", "Type", fmt.Sprintf("%T", obj)[1:], "Name", obj.GetName(), "Namespace", obj.GetNamespace())
+	err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setAppliedHash annotates obj with a hash of its content
+func setAppliedHash(obj client.Object) error {
+	annotations := make(map[string]string)
+	for k, v := range obj.GetAnnotations() {
+		annotations[k] = v
+	}
+	delete(annotations, appliedHashAnnotation)
+	obj.SetAnnotations(annotations)
+
+	hash, err := objectHash(obj)
+	if err != nil {
+		return err
+	}
+	annotations[appliedHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// objectHash returns a short hash of the JSON encoding of v
+func objectHash(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	hash := fnv.New64a()
+	hash.Write(data)
+	return fmt.Sprintf("%x", hash.Sum64()), nil
+}
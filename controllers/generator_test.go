@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+// configGenerator generates a ConfigMap holding data, or none when data is nil
+type configGenerator struct {
+	generatorDefaults
+	data map[string]string
+	skip bool
+}
+
+func (g *configGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g *configGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if g.skip {
+		return nil, errSkipResource
+	}
+	if g.data == nil {
+		return nil, nil
+	}
+	obj := g.object(app).(*corev1.ConfigMap)
+	obj.Data = make(map[string]string)
+	for k, v := range g.data {
+		obj.Data[k] = v
+	}
+	return obj, nil
+}
+
+func TestReconcileGenerated(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "uid"}}
+	c := newApplyClient(scheme)
+	r := &AppReconciler{Client: c, Log: ctrl.Log, Scheme: scheme}
+	g := &configGenerator{data: map[string]string{"a": "1", "b": "2"}}
+
+	existing := func() *corev1.ConfigMap {
+		t.Helper()
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, client.ObjectKey{Name: "web", Namespace: "team"}, cm)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cm
+	}
+	steps := []struct {
+		name   string
+		change func()
+		// applies is the total number of applies expected after the step
+		applies int
+		exists  bool
+	}{
+		{name: "created when missing", applies: 1, exists: true},
+		{name: "left alone when unchanged", applies: 1, exists: true},
+		{
+			name: "fields set by others are ignored",
+			change: func() {
+				cm := existing()
+				cm.Labels = map[string]string{"owner": "someone-else"}
+				if err := r.Update(ctx, cm); err != nil {
+					t.Fatal(err)
+				}
+			},
+			applies: 1,
+			exists:  true,
+		},
+		{
+			name: "drift in an applied field is reverted",
+			change: func() {
+				cm := existing()
+				cm.Data["a"] = "changed"
+				if err := r.Update(ctx, cm); err != nil {
+					t.Fatal(err)
+				}
+			},
+			applies: 2,
+			exists:  true,
+		},
+		{
+			// The existing object still holds b, so only the applied hash tells them apart
+			name:    "removed field is detected",
+			change:  func() { delete(g.data, "b") },
+			applies: 3,
+			exists:  true,
+		},
+		{name: "skipped resources are untouched", change: func() { g.skip = true; g.data = nil }, applies: 3, exists: true},
+		{name: "deleted when no longer desired", change: func() { g.skip = false }, applies: 3},
+	}
+
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		ready, _, err := r.reconcileGenerated(ctx, app, g)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if !ready {
+			t.Errorf("%s: not ready", step.name)
+		}
+		if len(c.applied) != step.applies {
+			t.Errorf("%s: %d applies, want %d", step.name, len(c.applied), step.applies)
+		}
+		if exists := existing() != nil; exists != step.exists {
+			t.Errorf("%s: exists %v, want %v", step.name, exists, step.exists)
+		}
+	}
+}
+
+func TestGeneratedLeavesUncontrolledResources(t *testing.T) {
+	scheme := testScheme(t)
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team", UID: "uid"}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	r := &AppReconciler{Client: newApplyClient(scheme, cm), Log: ctrl.Log, Scheme: scheme}
+
+	if _, _, err := r.reconcileGenerated(context.Background(), app, &configGenerator{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(cm), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("ConfigMap not controlled by the App: %v", err)
+	}
+}
+
+func TestAppliedHash(t *testing.T) {
+	newConfigMap := func(data map[string]string, annotations map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Annotations: annotations}, Data: data}
+	}
+	hash := func(cm *corev1.ConfigMap) string {
+		if err := setAppliedHash(cm); err != nil {
+			t.Fatal(err)
+		}
+		return cm.Annotations[appliedHashAnnotation]
+	}
+
+	base := hash(newConfigMap(map[string]string{"a": "1"}, nil))
+	if base == "" {
+		t.Fatal("no hash recorded")
+	}
+	if got := hash(newConfigMap(map[string]string{"a": "1"}, map[string]string{appliedHashAnnotation: "stale"})); got != base {
+		t.Errorf("hash %s with a stale hash annotation, want %s", got, base)
+	}
+	if got := hash(newConfigMap(map[string]string{"a": "2"}, nil)); got == base {
+		t.Error("hash unchanged by a different value")
+	}
+	if got := hash(newConfigMap(map[string]string{"a": "1", "b": "2"}, nil)); got == base {
+		t.Error("hash unchanged by an added key")
+	}
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// jobGenerator produces the Job running an App with the Job workload kind. The Job is named
+// after its pod template, so any change to what or how it runs runs the work again in a new Job.
+type jobGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g jobGenerator) object(app *kappv1alpha1.App) client.Object {
+	// An App the template can't be derived from has no Job under its name, and desired fails
+	name := app.Name
+	if spec, err := g.r.jobSpec(app); err == nil {
+		name = jobName(app, &spec.Template)
+	}
+	return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: app.Namespace}}
+}
+
+func (g jobGenerator) list() client.ObjectList {
+	return &batchv1.JobList{}
+}
+
+func (g jobGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if workloadKind(app) != kappv1alpha1.WorkloadJob {
+		return nil, nil
+	}
+	// Don't start a run the Environment's budget has no room for
+	if budgetInstances(app, 1) == 0 {
+		return nil, errSkipResource
+	}
+	return g.r.job(app)
+}
+
+// equal leaves an existing Job alone, since its template can't be changed once it exists.
+// A Job whose name matches already runs the desired template.
+func (g jobGenerator) equal(desired, existing client.Object) bool {
+	return true
+}
+
+// ready records the Job's status on the App and waits for it to complete
+func (g jobGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	job := obj.(*batchv1.Job)
+	clearWorkloadStatus(app)
+	app.Status.Job = job.Status.DeepCopy()
+	if failed, message := jobFailed(app.Status.Job); failed {
+		return false, fmt.Sprintf("Job failed: %s", message)
+	}
+	if job.Status.Succeeded == 0 {
+		return false, "Waiting for Job to complete"
+	}
+	return true, ""
+}
+
+// jobFailed reports whether the Job has given up retrying
+func jobFailed(status *batchv1.JobStatus) (bool, string) {
+	if status == nil {
+		return false, ""
+	}
+	for _, c := range status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return true, c.Message
+		}
+	}
+	return false, ""
+}
+
+// cronJobGenerator produces the CronJob running an App with the CronJob workload kind
+type cronJobGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g cronJobGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &batchv1beta1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g cronJobGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if workloadKind(app) != kappv1alpha1.WorkloadCronJob {
+		return nil, nil
+	}
+	return g.r.cronJob(app)
+}
+
+// ready records the CronJob's status on the App
+func (g cronJobGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	cronJob := obj.(*batchv1beta1.CronJob)
+	clearWorkloadStatus(app)
+	app.Status.CronJob = cronJob.Status.DeepCopy()
+	return true, ""
+}
+
+// batchWorkload reports whether the App runs to completion as a Job or CronJob
+func batchWorkload(app *kappv1alpha1.App) bool {
+	kind := workloadKind(app)
+	return kind == kappv1alpha1.WorkloadJob || kind == kappv1alpha1.WorkloadCronJob
+}
+
+func (r *AppReconciler) job(app *kappv1alpha1.App) (*batchv1.Job, error) {
+	spec, err := r.jobSpec(app)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobName(app, &spec.Template),
+			Namespace:   app.Namespace,
+			Labels:      spec.Template.Labels,
+			Annotations: app.Spec.Annotations,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// jobHashLength is the length of the hash suffix of Job names, leaving room for the App name
+// within kappv1alpha1.MaxJobAppNameLength
+const jobHashLength = 8
+
+// jobName names the App's Job after a hash of its pod template
+func jobName(app *kappv1alpha1.App, template *corev1.PodTemplateSpec) string {
+	hash, _ := objectHash(template)
+	return fmt.Sprintf("%s-%s", app.Name, hash[:jobHashLength])
+}
+
+func (r *AppReconciler) cronJob(app *kappv1alpha1.App) (*batchv1beta1.CronJob, error) {
+	spec, err := r.jobSpec(app)
+	if err != nil {
+		return nil, err
+	}
+	settings := jobSettings(app)
+
+	concurrencyPolicy := settings.ConcurrencyPolicy
+	if concurrencyPolicy == "" {
+		concurrencyPolicy = batchv1beta1.ForbidConcurrent
+	}
+	// Hold off scheduling runs the Environment's budget has no room for
+	suspend := settings.Suspend
+	if budgetInstances(app, 1) == 0 {
+		suspend = pointer.BoolPtr(true)
+	}
+
+	return &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        app.Name,
+			Namespace:   app.Namespace,
+			Labels:      spec.Template.Labels,
+			Annotations: app.Spec.Annotations,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   settings.Schedule,
+			ConcurrencyPolicy:          concurrencyPolicy,
+			Suspend:                    suspend,
+			SuccessfulJobsHistoryLimit: settings.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     settings.FailedJobsHistoryLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: spec.Template.Labels,
+				},
+				Spec: spec,
+			},
+		},
+	}, nil
+}
+
+// jobSpec derives a run-to-completion pod template from the App's deployment, keeping its
+// environment, config, volumes, ServiceAccount and security context
+func (r *AppReconciler) jobSpec(app *kappv1alpha1.App) (batchv1.JobSpec, error) {
+	dep, err := r.deployment(app)
+	if err != nil {
+		return batchv1.JobSpec{}, err
+	}
+	template := dep.Spec.Template
+	template.Name = ""
+	template.Namespace = ""
+	delete(template.Labels, trackLabel)
+
+	// An injected proxy never exits, which would keep the pod from completing
+	template.Annotations["sidecar.istio.io/inject"] = "false"
+
+	// Probes, ports and spreading across nodes only make sense for long-running instances
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	template.Spec.Affinity = nil
+	container := &template.Spec.Containers[0]
+	container.Ports = nil
+	container.ReadinessProbe = nil
+	container.LivenessProbe = nil
+	container.StartupProbe = nil
+
+	settings := jobSettings(app)
+	backoffLimit := int32(3)
+	if settings.BackoffLimit != nil {
+		backoffLimit = *settings.BackoffLimit
+	}
+
+	return batchv1.JobSpec{
+		BackoffLimit:          &backoffLimit,
+		ActiveDeadlineSeconds: settings.ActiveDeadlineSeconds,
+		Template:              template,
+	}, nil
+}
+
+func jobSettings(app *kappv1alpha1.App) kappv1alpha1.Job {
+	if app.Spec.Job == nil {
+		return kappv1alpha1.Job{}
+	}
+	return *app.Spec.Job
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+)
+
+func batchApp(kind string) *kappv1alpha1.App {
+	return &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "team"},
+		Spec: kappv1alpha1.AppSpec{
+			WorkloadKind:    kind,
+			Image:           "registry/report",
+			Version:         "v1",
+			Instances:       pointer.Int32Ptr(1),
+			Cpu:             "200m",
+			Memory:          "256Mi",
+			Port:            pointer.Int32Ptr(8080),
+			HealthCheckType: kappv1alpha1.HealthCheckTCP,
+			Env:             []corev1.EnvVar{{Name: "MODE", Value: "full"}},
+		},
+	}
+}
+
+func TestJobName(t *testing.T) {
+	r := &AppReconciler{}
+	name := func(app *kappv1alpha1.App) string {
+		t.Helper()
+		job, err := r.job(app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if object := (jobGenerator{r: r}).object(app); object.GetName() != job.Name {
+			t.Fatalf("existing Job read as %q, want %q", object.GetName(), job.Name)
+		}
+		return job.Name
+	}
+	base := name(batchApp(kappv1alpha1.WorkloadJob))
+	if again := name(batchApp(kappv1alpha1.WorkloadJob)); again != base {
+		t.Fatalf("name %q for the same App, want %q", again, base)
+	}
+
+	tests := []struct {
+		name   string
+		change func(app *kappv1alpha1.App)
+		rerun  bool
+	}{
+		{name: "new version", change: func(app *kappv1alpha1.App) { app.Spec.Version = "v2" }, rerun: true},
+		{name: "changed env", change: func(app *kappv1alpha1.App) { app.Spec.Env[0].Value = "incremental" }, rerun: true},
+		{
+			name: "init container command",
+			change: func(app *kappv1alpha1.App) {
+				app.Spec.InitContainers = []kappv1alpha1.InitContainer{{Name: "fetch", Command: []string{"fetch", "--all"}}}
+			},
+			rerun: true,
+		},
+		{name: "more memory", change: func(app *kappv1alpha1.App) { app.Spec.Memory = "1Gi" }, rerun: true},
+		{name: "node pinning", change: func(app *kappv1alpha1.App) { app.Spec.NodeSelector = map[string]string{"pool": "batch"} }, rerun: true},
+		{name: "instances", change: func(app *kappv1alpha1.App) { app.Spec.Instances = pointer.Int32Ptr(3) }},
+		{name: "retries", change: func(app *kappv1alpha1.App) { app.Spec.Job = &kappv1alpha1.Job{BackoffLimit: pointer.Int32Ptr(6)} }},
+	}
+	for _, tt := range tests {
+		app := batchApp(kappv1alpha1.WorkloadJob)
+		tt.change(app)
+		if rerun := name(app) != base; rerun != tt.rerun {
+			t.Errorf("%s: new Job %v, want %v", tt.name, rerun, tt.rerun)
+		}
+	}
+}
+
+func TestJobSpec(t *testing.T) {
+	r := &AppReconciler{}
+	app := batchApp(kappv1alpha1.WorkloadCronJob)
+	app.Spec.Job = &kappv1alpha1.Job{Schedule: "@daily"}
+
+	cronJob, err := r.cronJob(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cronJob.Spec.Schedule != "@daily" || cronJob.Spec.ConcurrencyPolicy != "Forbid" {
+		t.Errorf("schedule %q with concurrency %q, want @daily and Forbid", cronJob.Spec.Schedule, cronJob.Spec.ConcurrencyPolicy)
+	}
+
+	spec := cronJob.Spec.JobTemplate.Spec
+	if *spec.BackoffLimit != 3 {
+		t.Errorf("backoff limit %d, want 3", *spec.BackoffLimit)
+	}
+	template := spec.Template
+	if template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("restart policy %q, want Never", template.Spec.RestartPolicy)
+	}
+	if template.Annotations["sidecar.istio.io/inject"] != "false" {
+		t.Error("proxy injected into a batch pod")
+	}
+	container := template.Spec.Containers[0]
+	if container.Ports != nil || container.ReadinessProbe != nil || container.LivenessProbe != nil {
+		t.Errorf("container keeps ports %v and probes, want none", container.Ports)
+	}
+	if _, ok := template.Labels[trackLabel]; ok {
+		t.Error("batch pods labelled with a release track")
+	}
+}
+
+func TestBatchBudget(t *testing.T) {
+	r := &AppReconciler{}
+
+	cronApp := batchApp(kappv1alpha1.WorkloadCronJob)
+	cronApp.Spec.Job = &kappv1alpha1.Job{Schedule: "@daily"}
+	for allowed, suspend := range map[int32]bool{0: true, 1: false} {
+		cronApp.Status.AllowedInstances = pointer.Int32Ptr(allowed)
+		cronJob, err := r.cronJob(cronApp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if suspended := cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend; suspended != suspend {
+			t.Errorf("suspended %v with %d instances allowed, want %v", suspended, allowed, suspend)
+		}
+	}
+
+	// A Job over budget is left alone rather than started or deleted
+	jobApp := batchApp(kappv1alpha1.WorkloadJob)
+	jobApp.Status.AllowedInstances = pointer.Int32Ptr(0)
+	if _, err := (jobGenerator{r: r}).desired(jobApp); err != errSkipResource {
+		t.Errorf("desired returned %v with no instances allowed, want errSkipResource", err)
+	}
+}
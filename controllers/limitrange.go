@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func (r *EnvironmentReconciler) reconcileLimitRange(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	found := &corev1.LimitRange{}
+	desired := r.limitRange(env)
+	err := controllerutil.SetControllerReference(env, desired, r.Scheme)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.Create(ctx, desired); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.Log.Info("Created new LimitRange", "Name", desired.Name, "Namespace", desired.Namespace)
+			status.LimitRange = desired.Name
+			return ctrl.Result{Requeue: true}, nil
+		} else {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !r.limitRangeEquality(desired, found) {
+		desired.Spec.DeepCopyInto(&found.Spec)
+		found.Labels = desired.Labels
+		r.Log.Info("Updating LimitRange", "Name", desired.Name, "Namespace", desired.Namespace)
+		if err := r.Update(ctx, found); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	status.LimitRange = desired.Name
+	return ctrl.Result{}, nil
+}
+
+func (r *EnvironmentReconciler) limitRange(env *kappv1alpha1.Environment) *corev1.LimitRange {
+	defaultRequest := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	}
+	if len(env.Spec.DefaultRequest) > 0 {
+		defaultRequest = env.Spec.DefaultRequest.DeepCopy()
+	}
+
+	defaultLimit := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("512Mi"),
+	}
+	if len(env.Spec.DefaultLimit) > 0 {
+		defaultLimit = env.Spec.DefaultLimit.DeepCopy()
+	}
+
+	// No container may ask for more than the whole budget, and the defaults must fit under that
+	containerMax := corev1.ResourceList{}
+	if budget := env.Spec.Budget; budget != nil {
+		for name, value := range map[corev1.ResourceName]*resource.Quantity{
+			corev1.ResourceCPU:    budget.Cpu,
+			corev1.ResourceMemory: budget.Memory,
+		} {
+			if value == nil {
+				continue
+			}
+			containerMax[name] = value.DeepCopy()
+			for _, defaults := range []corev1.ResourceList{defaultRequest, defaultLimit} {
+				if q, ok := defaults[name]; ok && q.Cmp(*value) > 0 {
+					defaults[name] = value.DeepCopy()
+				}
+			}
+		}
+	}
+
+	return &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.Name,
+			Namespace: env.Name,
+			Labels: map[string]string{
+				environmentLabel: env.Name,
+			},
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type:           corev1.LimitTypeContainer,
+					DefaultRequest: defaultRequest,
+					Default:        defaultLimit,
+					Max:            containerMax,
+				},
+			},
+		},
+	}
+}
+
+func (r *EnvironmentReconciler) logLimitRangeInequality(lr *corev1.LimitRange, propertyName string, desired, actual interface{}) {
+	r.logDifference(desired, actual, propertyName, lr.Name, lr.Namespace, lr.TypeMeta)
+}
+
+func (r *EnvironmentReconciler) limitRangeEquality(desired *corev1.LimitRange, actual *corev1.LimitRange) bool {
+	// Validate all desired labels are present
+	if !mapMatch(desired.Labels, actual.Labels) {
+		r.logLimitRangeInequality(desired, "labels", desired.Labels, actual.Labels)
+		return false
+	}
+
+	// Validate limits are correct
+	if len(desired.Spec.Limits) != len(actual.Spec.Limits) {
+		r.logLimitRangeInequality(desired, "limits", desired.Spec.Limits, actual.Spec.Limits)
+		return false
+	}
+	for i := range desired.Spec.Limits {
+		dl := desired.Spec.Limits[i]
+		al := actual.Spec.Limits[i]
+		if dl.Type != al.Type ||
+			!resourceListMatch(dl.Max, al.Max) ||
+			!resourceListMatch(dl.Min, al.Min) ||
+			!resourceListMatch(dl.Default, al.Default) ||
+			!resourceListMatch(dl.DefaultRequest, al.DefaultRequest) {
+			r.logLimitRangeInequality(desired, "limits", desired.Spec.Limits, actual.Spec.Limits)
+			return false
+		}
+	}
+
+	return true
+}
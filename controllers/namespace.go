@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// environmentLabel is set on every resource provisioned for an Environment
+const environmentLabel = "kappa.io/environment"
+
+func (r *EnvironmentReconciler) reconcileNamespace(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	found := &corev1.Namespace{}
+	desired := r.namespace(env)
+	err := controllerutil.SetControllerReference(env, desired, r.Scheme)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.Create(ctx, desired); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.Log.Info("Created new Namespace", "Name", desired.Name)
+			status.Namespace = desired.Name
+			return ctrl.Result{Requeue: true}, nil
+		} else {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !r.namespaceEquality(desired, found) {
+		// Merge rather than replace so labels set by other controllers survive
+		for k, v := range desired.Labels {
+			if found.Labels == nil {
+				found.Labels = make(map[string]string)
+			}
+			found.Labels[k] = v
+		}
+		for k, v := range desired.Annotations {
+			if found.Annotations == nil {
+				found.Annotations = make(map[string]string)
+			}
+			found.Annotations[k] = v
+		}
+		r.Log.Info("Updating Namespace", "Name", desired.Name)
+		if err := r.Update(ctx, found); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	status.Namespace = desired.Name
+	return ctrl.Result{}, nil
+}
+
+func (r *EnvironmentReconciler) namespace(env *kappv1alpha1.Environment) *corev1.Namespace {
+	labels := make(map[string]string)
+	for k, v := range env.Spec.Labels {
+		labels[k] = v
+	}
+	labels[environmentLabel] = env.Name
+	// Kubernetes only sets the name label itself from 1.21 on, see namespaceNameLabel
+	labels[namespaceNameLabel] = env.Name
+	if env.Spec.IstioInjection == nil || *env.Spec.IstioInjection {
+		labels["istio-injection"] = "enabled"
+	} else {
+		labels["istio-injection"] = "disabled"
+	}
+
+	annotations := make(map[string]string)
+	for k, v := range env.Spec.Annotations {
+		annotations[k] = v
+	}
+
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        env.Name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+}
+
+func (r *EnvironmentReconciler) logNamespaceInequality(ns *corev1.Namespace, propertyName string, desired, actual interface{}) {
+	r.logDifference(desired, actual, propertyName, ns.Name, ns.Namespace, ns.TypeMeta)
+}
+
+func (r *EnvironmentReconciler) namespaceEquality(desired *corev1.Namespace, actual *corev1.Namespace) bool {
+	// Validate all desired labels are present
+	if !mapMatch(desired.Labels, actual.Labels) {
+		r.logNamespaceInequality(desired, "labels", desired.Labels, actual.Labels)
+		return false
+	}
+
+	// Validate all desired annotations are present
+	if !mapMatch(desired.Annotations, actual.Annotations) {
+		r.logNamespaceInequality(desired, "annotations", desired.Annotations, actual.Annotations)
+		return false
+	}
+
+	return true
+}
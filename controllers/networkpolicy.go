@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+)
+
+const (
+	// namespaceNameLabel holds a namespace's name. Kubernetes sets it on every namespace from 1.21
+	// on, and kappa on the namespaces it provisions. On older clusters the gateway's and the Istio
+	// control plane's namespaces, and those named in ingressFrom, need it set by hand.
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	// istioNamespace runs the Istio control plane every sidecar must reach
+	istioNamespace = "istio-system"
+	// defaultDenyPolicyName is the Environment's baseline NetworkPolicy
+	defaultDenyPolicyName = "default-deny"
+)
+
+func (r *AppReconciler) reconcileNetworkPolicy(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
+	env := &kappv1alpha1.Environment{}
+	err := r.Get(ctx, types.NamespacedName{Name: app.Namespace}, env)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	defaultDeny := err == nil && env.Spec.DefaultDenyNetwork != nil && *env.Spec.DefaultDenyNetwork
+
+	_, _, err = r.reconcileGenerated(ctx, app, networkPolicyGenerator{r: r, defaultDeny: defaultDeny})
+	return ctrl.Result{}, err
+}
+
+// networkPolicyGenerator produces the NetworkPolicy isolating the App's pods, once the App declares
+// its callers or destinations or its Environment denies traffic by default
+type networkPolicyGenerator struct {
+	generatorDefaults
+	r           *AppReconciler
+	defaultDeny bool
+}
+
+func (g networkPolicyGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g networkPolicyGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !g.defaultDeny && len(app.Spec.IngressFrom) == 0 && len(app.Spec.EgressTo) == 0 {
+		return nil, nil
+	}
+	return g.r.networkPolicy(app), nil
+}
+
+func (r *AppReconciler) networkPolicy(app *kappv1alpha1.App) *networkingv1.NetworkPolicy {
+	labels := appLabels(app)
+
+	var ingress []networkingv1.NetworkPolicyIngressRule
+	if from := networkPolicyPeers(app, app.Spec.IngressFrom); len(from) > 0 {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{From: from})
+	}
+	if isPublic(app) && receivesTraffic(app) && r.Gateway != "" {
+		ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{namespaceNameLabel: strings.SplitN(r.Gateway, "/", 2)[0]},
+					},
+					PodSelector: &metav1.LabelSelector{
+						MatchLabels: r.GatewaySelector,
+					},
+				},
+			},
+		})
+	}
+
+	policyTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	var egress []networkingv1.NetworkPolicyEgressRule
+	if len(app.Spec.EgressTo) > 0 {
+		policyTypes = append(policyTypes, networkingv1.PolicyTypeEgress)
+		egress = append(baselineEgress(), networkingv1.NetworkPolicyEgressRule{
+			To: networkPolicyPeers(app, app.Spec.EgressTo),
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        app.Name,
+			Namespace:   app.Namespace,
+			Labels:      labels,
+			Annotations: app.Spec.Annotations,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": app.Name},
+			},
+			PolicyTypes: policyTypes,
+			Ingress:     ingress,
+			Egress:      egress,
+		},
+	}
+}
+
+// networkPolicyPeers selects the pods of each peer App, or its address range
+func networkPolicyPeers(app *kappv1alpha1.App, peers []kappv1alpha1.NetworkPeer) []networkingv1.NetworkPolicyPeer {
+	var result []networkingv1.NetworkPolicyPeer
+	for _, peer := range peers {
+		if peer.Cidr != "" {
+			result = append(result, networkingv1.NetworkPolicyPeer{
+				IPBlock: &networkingv1.IPBlock{CIDR: peer.Cidr},
+			})
+			continue
+		}
+
+		p := networkingv1.NetworkPolicyPeer{
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": peer.App},
+			},
+		}
+		if peer.Namespace != "" && peer.Namespace != app.Namespace {
+			p.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{namespaceNameLabel: peer.Namespace},
+			}
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// baselineEgress allows what every pod needs to run in the mesh: DNS and the Istio control plane
+func baselineEgress() []networkingv1.NetworkPolicyEgressRule {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dns := intstr.FromInt(53)
+	return []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dns},
+				{Protocol: &tcp, Port: &dns},
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{namespaceNameLabel: istioNamespace},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *EnvironmentReconciler) reconcileNetworkPolicy(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	found := &networkingv1.NetworkPolicy{}
+	desired := r.defaultDenyPolicy(env)
+	err := controllerutil.SetControllerReference(env, desired, r.Scheme)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	exists := err == nil
+
+	if env.Spec.DefaultDenyNetwork == nil || !*env.Spec.DefaultDenyNetwork {
+		status.NetworkPolicy = ""
+		if exists && metav1.IsControlledBy(found, env) {
+			r.Log.Info("Deleting NetworkPolicy", "Name", found.Name, "Namespace", found.Namespace)
+			if err := r.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !exists {
+		if err = r.Create(ctx, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Created new NetworkPolicy", "Name", desired.Name, "Namespace", desired.Namespace)
+		status.NetworkPolicy = desired.Name
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if !mapMatch(desired.Labels, found.Labels) || !reflect.DeepEqual(desired.Spec, found.Spec) {
+		r.logDifference(desired.Spec, found.Spec, "spec", desired.Name, desired.Namespace, desired.TypeMeta)
+		desired.Spec.DeepCopyInto(&found.Spec)
+		found.Labels = desired.Labels
+		r.Log.Info("Updating NetworkPolicy", "Name", desired.Name, "Namespace", desired.Namespace)
+		if err := r.Update(ctx, found); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	status.NetworkPolicy = desired.Name
+	return ctrl.Result{}, nil
+}
+
+// defaultDenyPolicy denies all traffic to and from the Environment's pods but DNS and the Istio control plane
+func (r *EnvironmentReconciler) defaultDenyPolicy(env *kappv1alpha1.Environment) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      defaultDenyPolicyName,
+			Namespace: env.Name,
+			Labels: map[string]string{
+				environmentLabel: env.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Egress:      baselineEgress(),
+		},
+	}
+}
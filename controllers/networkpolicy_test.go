@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkPolicyPeers(t *testing.T) {
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+	peers := networkPolicyPeers(app, []kappv1alpha1.NetworkPeer{
+		{App: "api"},
+		{App: "db", Namespace: "team"},
+		{App: "billing", Namespace: "payments"},
+		{Cidr: "10.0.0.0/8"},
+	})
+
+	want := []networkingv1.NetworkPolicyPeer{
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+		{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+		{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "billing"}},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "payments"}},
+		},
+		{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Errorf("peers = %+v, want %+v", peers, want)
+	}
+}
+
+func TestNetworkPolicy(t *testing.T) {
+	r := &AppReconciler{Gateway: "istio-system/public", GatewaySelector: map[string]string{"istio": "public-gateway"}}
+	app := &kappv1alpha1.App{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"},
+		Spec:       kappv1alpha1.AppSpec{Public: pointer.BoolPtr(false), IngressFrom: []kappv1alpha1.NetworkPeer{{App: "api"}}},
+	}
+
+	// Callers only: egress stays open
+	policy := r.networkPolicy(app)
+	if !reflect.DeepEqual(policy.Spec.PolicyTypes, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}) || policy.Spec.Egress != nil {
+		t.Errorf("policy types %v with egress %v, want ingress only", policy.Spec.PolicyTypes, policy.Spec.Egress)
+	}
+	if len(policy.Spec.Ingress) != 1 || len(policy.Spec.Ingress[0].From) != 1 {
+		t.Fatalf("ingress = %+v, want one rule for api", policy.Spec.Ingress)
+	}
+	if policy.Spec.PodSelector.MatchLabels["app"] != "web" {
+		t.Errorf("pod selector %v, want the App's pods", policy.Spec.PodSelector)
+	}
+
+	// Public Apps also accept the ingress gateway's pods
+	app.Spec.Public = pointer.BoolPtr(true)
+	policy = r.networkPolicy(app)
+	if len(policy.Spec.Ingress) != 2 {
+		t.Fatalf("ingress = %+v, want a rule for the gateway", policy.Spec.Ingress)
+	}
+	gateway := policy.Spec.Ingress[1].From[0]
+	if gateway.NamespaceSelector.MatchLabels[namespaceNameLabel] != "istio-system" || !reflect.DeepEqual(gateway.PodSelector.MatchLabels, r.GatewaySelector) {
+		t.Errorf("gateway peer %+v, want the gateway pods in istio-system", gateway)
+	}
+
+	// Destinations restrict egress, keeping DNS and the control plane reachable
+	app.Spec.EgressTo = []kappv1alpha1.NetworkPeer{{Cidr: "192.168.0.0/16"}}
+	policy = r.networkPolicy(app)
+	if len(policy.Spec.PolicyTypes) != 2 {
+		t.Errorf("policy types %v, want ingress and egress", policy.Spec.PolicyTypes)
+	}
+	baseline := baselineEgress()
+	if len(policy.Spec.Egress) != len(baseline)+1 || !reflect.DeepEqual(policy.Spec.Egress[:len(baseline)], baseline) {
+		t.Fatalf("egress = %+v, want the baseline followed by the destinations", policy.Spec.Egress)
+	}
+	if to := policy.Spec.Egress[len(baseline)].To; len(to) != 1 || to[0].IPBlock.CIDR != "192.168.0.0/16" {
+		t.Errorf("egress to %+v, want 192.168.0.0/16", to)
+	}
+}
+
+func TestNetworkPolicyGenerator(t *testing.T) {
+	r := &AppReconciler{Gateway: "istio-system/public"}
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+
+	if obj, _ := (networkPolicyGenerator{r: r}).desired(app); obj != nil {
+		t.Errorf("policy %v for an App without peers, want none", obj)
+	}
+
+	// Under a default-deny Environment the App needs its own policy to be reachable at all
+	obj, _ := networkPolicyGenerator{r: r, defaultDeny: true}.desired(app)
+	if obj == nil {
+		t.Fatal("no policy for an App in a default-deny Environment")
+	}
+	if ingress := obj.(*networkingv1.NetworkPolicy).Spec.Ingress; len(ingress) != 1 {
+		t.Errorf("ingress = %+v, want the gateway rule of a public App", ingress)
+	}
+}
+
+func TestDefaultDenyPolicy(t *testing.T) {
+	r := &EnvironmentReconciler{}
+	policy := r.defaultDenyPolicy(&kappv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "team"}})
+
+	if policy.Namespace != "team" || len(policy.Spec.PodSelector.MatchLabels) != 0 {
+		t.Errorf("policy in %q selecting %v, want every pod in team", policy.Namespace, policy.Spec.PodSelector)
+	}
+	if len(policy.Spec.PolicyTypes) != 2 || policy.Spec.Ingress != nil {
+		t.Errorf("policy types %v with ingress %v, want all ingress and egress denied", policy.Spec.PolicyTypes, policy.Spec.Ingress)
+	}
+	if !reflect.DeepEqual(policy.Spec.Egress, baselineEgress()) {
+		t.Errorf("egress = %+v, want only the baseline", policy.Spec.Egress)
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"fmt"
+
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// probeTiming is the timing a probe falls back to when the App doesn't override it
+type probeTiming struct {
+	initialDelay     int32
+	failureThreshold int32
+}
+
+func readinessProbe(app *kappv1alpha1.App) *corev1.Probe {
+	var settings *kappv1alpha1.Probe
+	if app.Spec.Probes != nil {
+		settings = app.Spec.Probes.Readiness
+	}
+	return probe(app, settings, probeTiming{initialDelay: 10, failureThreshold: 12})
+}
+
+func livenessProbe(app *kappv1alpha1.App) *corev1.Probe {
+	var settings *kappv1alpha1.Probe
+	if app.Spec.Probes != nil {
+		settings = app.Spec.Probes.Liveness
+	}
+	return probe(app, settings, probeTiming{initialDelay: 120, failureThreshold: 1})
+}
+
+// startupProbe returns the application container's startup probe, only when the App asks for one
+func startupProbe(app *kappv1alpha1.App) *corev1.Probe {
+	if app.Spec.Probes == nil || app.Spec.Probes.Startup == nil {
+		return nil
+	}
+	return probe(app, app.Spec.Probes.Startup, probeTiming{failureThreshold: 30})
+}
+
+// probe builds one of the application container's probes from the App's health check, overridden by
+// the probe's settings. It returns nil when the check needs a port and a worker App has none.
+func probe(app *kappv1alpha1.App, settings *kappv1alpha1.Probe, timing probeTiming) *corev1.Probe {
+	if settings == nil {
+		settings = &kappv1alpha1.Probe{}
+	}
+
+	probeType := settings.Type
+	if probeType == "" {
+		probeType = app.Spec.HealthCheckType
+	}
+	port := settings.Port
+	if port == nil {
+		port = appPort(app)
+	}
+
+	var handler corev1.Handler
+	switch probeType {
+	case kappv1alpha1.HealthCheckExec:
+		command := settings.Command
+		if len(command) == 0 {
+			command = app.Spec.HealthCheckCommand
+		}
+		handler.Exec = &corev1.ExecAction{Command: command}
+	case kappv1alpha1.HealthCheckGRPC:
+		if port == nil {
+			return nil
+		}
+		// Probes in this API version have no gRPC handler, so run the standard health check client
+		command := []string{"grpc_health_probe", fmt.Sprintf("-addr=:%d", *port)}
+		if settings.Service != "" {
+			command = append(command, fmt.Sprintf("-service=%s", settings.Service))
+		}
+		handler.Exec = &corev1.ExecAction{Command: command}
+	case kappv1alpha1.HealthCheckHTTP:
+		if port == nil {
+			return nil
+		}
+		path := settings.Path
+		if path == "" {
+			path = app.Spec.HealthCheckEndpoint
+		}
+		scheme := settings.Scheme
+		if scheme == "" {
+			scheme = corev1.URISchemeHTTP
+		}
+		handler.HTTPGet = &corev1.HTTPGetAction{
+			Port:        intstr.FromInt(int(*port)),
+			Path:        path,
+			Scheme:      scheme,
+			HTTPHeaders: settings.Headers,
+		}
+	default:
+		if port == nil {
+			return nil
+		}
+		handler.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(int(*port))}
+	}
+
+	p := &corev1.Probe{
+		Handler:             handler,
+		InitialDelaySeconds: timing.initialDelay,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      10,
+		SuccessThreshold:    1,
+		FailureThreshold:    timing.failureThreshold,
+	}
+	if handler.TCPSocket != nil {
+		p.TimeoutSeconds = 1
+	}
+	for _, override := range []struct {
+		value *int32
+		field *int32
+	}{
+		{settings.InitialDelaySeconds, &p.InitialDelaySeconds},
+		{settings.PeriodSeconds, &p.PeriodSeconds},
+		{settings.TimeoutSeconds, &p.TimeoutSeconds},
+		{settings.SuccessThreshold, &p.SuccessThreshold},
+		{settings.FailureThreshold, &p.FailureThreshold},
+	} {
+		if override.value != nil {
+			*override.field = *override.value
+		}
+	}
+	return p
+}
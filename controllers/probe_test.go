@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	"reflect"
+	"testing"
+)
+
+func TestProbeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     kappv1alpha1.AppSpec
+		settings *kappv1alpha1.Probe
+		handler  *corev1.Handler
+	}{
+		{
+			name:    "tcp on the default port",
+			handler: &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+		},
+		{
+			name:     "tcp on the probe's port",
+			spec:     kappv1alpha1.AppSpec{Port: pointer.Int32Ptr(9000)},
+			settings: &kappv1alpha1.Probe{Port: pointer.Int32Ptr(9090)},
+			handler:  &corev1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(9090)}},
+		},
+		{
+			name: "http from the health check",
+			spec: kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckHTTP, HealthCheckEndpoint: "/healthz"},
+			handler: &corev1.Handler{HTTPGet: &corev1.HTTPGetAction{
+				Port:   intstr.FromInt(8080),
+				Path:   "/healthz",
+				Scheme: corev1.URISchemeHTTP,
+			}},
+		},
+		{
+			name: "http overridden by the probe",
+			spec: kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckHTTP, HealthCheckEndpoint: "/healthz"},
+			settings: &kappv1alpha1.Probe{
+				Path:    "/ready",
+				Scheme:  corev1.URISchemeHTTPS,
+				Headers: []corev1.HTTPHeader{{Name: "X-Probe", Value: "1"}},
+			},
+			handler: &corev1.Handler{HTTPGet: &corev1.HTTPGetAction{
+				Port:        intstr.FromInt(8080),
+				Path:        "/ready",
+				Scheme:      corev1.URISchemeHTTPS,
+				HTTPHeaders: []corev1.HTTPHeader{{Name: "X-Probe", Value: "1"}},
+			}},
+		},
+		{
+			name:    "exec from the health check",
+			spec:    kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckExec, HealthCheckCommand: []string{"check"}},
+			handler: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"check"}}},
+		},
+		{
+			name:     "exec overridden by the probe",
+			spec:     kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckExec, HealthCheckCommand: []string{"check"}},
+			settings: &kappv1alpha1.Probe{Command: []string{"ready"}},
+			handler:  &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"ready"}}},
+		},
+		{
+			name:     "grpc with a service",
+			spec:     kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckGRPC},
+			settings: &kappv1alpha1.Probe{Service: "api"},
+			handler:  &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"grpc_health_probe", "-addr=:8080", "-service=api"}}},
+		},
+		{
+			name: "worker without a port",
+			spec: kappv1alpha1.AppSpec{Worker: pointer.BoolPtr(true)},
+		},
+		{
+			name:    "worker with an exec check",
+			spec:    kappv1alpha1.AppSpec{Worker: pointer.BoolPtr(true), HealthCheckType: kappv1alpha1.HealthCheckExec, HealthCheckCommand: []string{"check"}},
+			handler: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"check"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{Spec: tt.spec}
+			p := probe(app, tt.settings, probeTiming{})
+			if tt.handler == nil {
+				if p != nil {
+					t.Errorf("probe = %v, want none", p)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("probe = nil")
+			}
+			if !reflect.DeepEqual(p.Handler, *tt.handler) {
+				t.Errorf("handler = %v, want %v", p.Handler, *tt.handler)
+			}
+		})
+	}
+}
+
+func TestProbeTiming(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  kappv1alpha1.AppSpec
+		build func(app *kappv1alpha1.App) *corev1.Probe
+		want  *corev1.Probe
+	}{
+		{
+			name:  "readiness defaults",
+			spec:  kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckExec},
+			build: readinessProbe,
+			want:  &corev1.Probe{InitialDelaySeconds: 10, PeriodSeconds: 10, TimeoutSeconds: 10, SuccessThreshold: 1, FailureThreshold: 12},
+		},
+		{
+			name:  "liveness defaults",
+			spec:  kappv1alpha1.AppSpec{HealthCheckType: kappv1alpha1.HealthCheckExec},
+			build: livenessProbe,
+			want:  &corev1.Probe{InitialDelaySeconds: 120, PeriodSeconds: 10, TimeoutSeconds: 10, SuccessThreshold: 1, FailureThreshold: 1},
+		},
+		{
+			name:  "tcp checks time out sooner",
+			build: readinessProbe,
+			want:  &corev1.Probe{InitialDelaySeconds: 10, PeriodSeconds: 10, TimeoutSeconds: 1, SuccessThreshold: 1, FailureThreshold: 12},
+		},
+		{
+			name:  "no startup probe unless asked for",
+			build: startupProbe,
+		},
+		{
+			name: "startup defaults",
+			spec: kappv1alpha1.AppSpec{
+				HealthCheckType: kappv1alpha1.HealthCheckExec,
+				Probes:          &kappv1alpha1.Probes{Startup: &kappv1alpha1.Probe{}},
+			},
+			build: startupProbe,
+			want:  &corev1.Probe{PeriodSeconds: 10, TimeoutSeconds: 10, SuccessThreshold: 1, FailureThreshold: 30},
+		},
+		{
+			name: "overridden by the probe",
+			spec: kappv1alpha1.AppSpec{
+				HealthCheckType: kappv1alpha1.HealthCheckExec,
+				Probes: &kappv1alpha1.Probes{Readiness: &kappv1alpha1.Probe{
+					InitialDelaySeconds: pointer.Int32Ptr(0),
+					PeriodSeconds:       pointer.Int32Ptr(5),
+					TimeoutSeconds:      pointer.Int32Ptr(2),
+					SuccessThreshold:    pointer.Int32Ptr(3),
+					FailureThreshold:    pointer.Int32Ptr(4),
+				}},
+			},
+			build: readinessProbe,
+			want:  &corev1.Probe{InitialDelaySeconds: 0, PeriodSeconds: 5, TimeoutSeconds: 2, SuccessThreshold: 3, FailureThreshold: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.build(&kappv1alpha1.App{Spec: tt.spec})
+			if tt.want == nil {
+				if p != nil {
+					t.Errorf("probe = %v, want none", p)
+				}
+				return
+			}
+			if p == nil {
+				t.Fatal("probe = nil")
+			}
+			p.Handler = corev1.Handler{}
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("probe = %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func (r *EnvironmentReconciler) reconcileResourceQuota(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	found := &corev1.ResourceQuota{}
+	desired := r.resourceQuota(env)
+	err := controllerutil.SetControllerReference(env, desired, r.Scheme)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.Create(ctx, desired); err != nil {
+				return ctrl.Result{}, err
+			}
+			r.Log.Info("Created new ResourceQuota", "Name", desired.Name, "Namespace", desired.Namespace)
+			status.ResourceQuota = desired.Name
+			return ctrl.Result{Requeue: true}, nil
+		} else {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !r.resourceQuotaEquality(desired, found) {
+		desired.Spec.DeepCopyInto(&found.Spec)
+		found.Labels = desired.Labels
+		r.Log.Info("Updating ResourceQuota", "Name", desired.Name, "Namespace", desired.Namespace)
+		if err := r.Update(ctx, found); err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+	}
+
+	status.ResourceQuota = desired.Name
+	return ctrl.Result{}, nil
+}
+
+// podCountResource is the object count quota on pods
+const podCountResource corev1.ResourceName = "count/pods"
+
+func (r *EnvironmentReconciler) resourceQuota(env *kappv1alpha1.Environment) *corev1.ResourceQuota {
+	hard := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	if len(env.Spec.Quota) > 0 {
+		hard = env.Spec.Quota.DeepCopy()
+	}
+	// The quota backs the budget up for pods created outside of Apps. Apps are held within the
+	// budget before they reach it, see checkBudget.
+	if budget := env.Spec.Budget; budget != nil {
+		if budget.Cpu != nil {
+			hard[corev1.ResourceCPU] = budget.Cpu.DeepCopy()
+		}
+		if budget.Memory != nil {
+			hard[corev1.ResourceMemory] = budget.Memory.DeepCopy()
+		}
+		if budget.Instances != nil {
+			hard[podCountResource] = *resource.NewQuantity(int64(*budget.Instances), resource.DecimalSI)
+		}
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.Name,
+			Namespace: env.Name,
+			Labels: map[string]string{
+				environmentLabel: env.Name,
+			},
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: hard,
+		},
+	}
+}
+
+func (r *EnvironmentReconciler) logResourceQuotaInequality(rq *corev1.ResourceQuota, propertyName string, desired, actual interface{}) {
+	r.logDifference(desired, actual, propertyName, rq.Name, rq.Namespace, rq.TypeMeta)
+}
+
+func (r *EnvironmentReconciler) resourceQuotaEquality(desired *corev1.ResourceQuota, actual *corev1.ResourceQuota) bool {
+	// Validate all desired labels are present
+	if !mapMatch(desired.Labels, actual.Labels) {
+		r.logResourceQuotaInequality(desired, "labels", desired.Labels, actual.Labels)
+		return false
+	}
+
+	// Validate hard limits are correct
+	if !resourceListMatch(desired.Spec.Hard, actual.Spec.Hard) {
+		r.logResourceQuotaInequality(desired, "hard", desired.Spec.Hard, actual.Spec.Hard)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"testing"
+)
+
+func TestResourceQuota(t *testing.T) {
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+	hard := func(values map[corev1.ResourceName]string) corev1.ResourceList {
+		list := corev1.ResourceList{}
+		for name, value := range values {
+			list[name] = resource.MustParse(value)
+		}
+		return list
+	}
+
+	tests := []struct {
+		name string
+		spec kappv1alpha1.EnvironmentSpec
+		want corev1.ResourceList
+	}{
+		{
+			name: "defaults",
+			want: hard(map[corev1.ResourceName]string{corev1.ResourceCPU: "4", corev1.ResourceMemory: "8Gi"}),
+		},
+		{
+			name: "budget replaces the defaults",
+			spec: kappv1alpha1.EnvironmentSpec{Budget: &kappv1alpha1.Budget{Cpu: quantity("10"), Instances: pointer.Int32Ptr(12)}},
+			want: hard(map[corev1.ResourceName]string{corev1.ResourceCPU: "10", corev1.ResourceMemory: "8Gi", podCountResource: "12"}),
+		},
+		{
+			name: "budget replaces the matching quota",
+			spec: kappv1alpha1.EnvironmentSpec{
+				Quota:  hard(map[corev1.ResourceName]string{corev1.ResourceMemory: "4Gi", corev1.ResourceServices: "5"}),
+				Budget: &kappv1alpha1.Budget{Memory: quantity("16Gi")},
+			},
+			want: hard(map[corev1.ResourceName]string{corev1.ResourceMemory: "16Gi", corev1.ResourceServices: "5"}),
+		},
+	}
+
+	r := &EnvironmentReconciler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := r.resourceQuota(&kappv1alpha1.Environment{ObjectMeta: metav1.ObjectMeta{Name: "team"}, Spec: tt.spec})
+			if !resourceListMatch(tt.want, quota.Spec.Hard) {
+				t.Errorf("hard = %v, want %v", quota.Spec.Hard, tt.want)
+			}
+		})
+	}
+}
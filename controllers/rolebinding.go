@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func (r *EnvironmentReconciler) reconcileRoleBindings(ctx context.Context, env *kappv1alpha1.Environment, status *kappv1alpha1.EnvironmentStatus) (ctrl.Result, error) {
+	status.RoleBindings = nil
+	desiredNames := make(map[string]bool)
+	for _, team := range env.Spec.Teams {
+		desired := r.roleBinding(env, team)
+		desiredNames[desired.Name] = true
+		if err := r.reconcileRoleBinding(ctx, env, desired); err != nil {
+			return ctrl.Result{}, err
+		}
+		status.RoleBindings = append(status.RoleBindings, desired.Name)
+	}
+
+	// Remove bindings for teams that are no longer listed
+	existing := &rbacv1.RoleBindingList{}
+	err := r.List(ctx, existing, client.InNamespace(env.Name), client.MatchingLabels{environmentLabel: env.Name})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range existing.Items {
+		rb := &existing.Items[i]
+		if desiredNames[rb.Name] || !metav1.IsControlledBy(rb, env) {
+			continue
+		}
+		r.Log.Info("Deleting RoleBinding", "Name", rb.Name, "Namespace", rb.Namespace)
+		if err := r.Delete(ctx, rb); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *EnvironmentReconciler) reconcileRoleBinding(ctx context.Context, env *kappv1alpha1.Environment, desired *rbacv1.RoleBinding) error {
+	found := &rbacv1.RoleBinding{}
+	err := controllerutil.SetControllerReference(env, desired, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if err = r.Create(ctx, desired); err != nil {
+				return err
+			}
+			r.Log.Info("Created new RoleBinding", "Name", desired.Name, "Namespace", desired.Namespace)
+			return nil
+		}
+		return err
+	}
+
+	// RoleRef is immutable, so a changed role requires recreating the binding
+	if !reflect.DeepEqual(desired.RoleRef, found.RoleRef) {
+		r.logDifference(desired.RoleRef, found.RoleRef, "roleRef", desired.Name, desired.Namespace, desired.TypeMeta)
+		if err := r.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return r.Create(ctx, desired)
+	}
+
+	if !mapMatch(desired.Labels, found.Labels) || !reflect.DeepEqual(desired.Subjects, found.Subjects) {
+		r.logDifference(desired.Subjects, found.Subjects, "subjects", desired.Name, desired.Namespace, desired.TypeMeta)
+		found.Labels = desired.Labels
+		found.Subjects = desired.Subjects
+		r.Log.Info("Updating RoleBinding", "Name", desired.Name, "Namespace", desired.Namespace)
+		if err := r.Update(ctx, found); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *EnvironmentReconciler) roleBinding(env *kappv1alpha1.Environment, team kappv1alpha1.TeamAccess) *rbacv1.RoleBinding {
+	role := team.Role
+	if role == "" {
+		role = "edit"
+	}
+
+	var subjects []rbacv1.Subject
+	for _, group := range team.Groups {
+		subjects = append(subjects, rbacv1.Subject{
+			Kind:     rbacv1.GroupKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     group,
+		})
+	}
+	for _, user := range team.Users {
+		subjects = append(subjects, rbacv1.Subject{
+			Kind:     rbacv1.UserKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     user,
+		})
+	}
+
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("team-%s", team.Name),
+			Namespace: env.Name,
+			Labels: map[string]string{
+				environmentLabel: env.Name,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role,
+		},
+		Subjects: subjects,
+	}
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestServiceAnnotations(t *testing.T) {
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web",
+		Namespace: "team",
+		Annotations: map[string]string{
+			"prometheus.io/scrape":                             "true",
+			kappv1alpha1.PromoteAnnotation:                     "v2",
+			"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		},
+	}}
+
+	service := (&AppReconciler{}).service(app)
+	if want := map[string]string{"prometheus.io/scrape": "true"}; !reflect.DeepEqual(service.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", service.Annotations, want)
+	}
+
+	app.Annotations = map[string]string{kappv1alpha1.PromoteAnnotation: "v2"}
+	if service := (&AppReconciler{}).service(app); service.Annotations != nil {
+		t.Errorf("annotations = %v, want none", service.Annotations)
+	}
+}
@@ -1,53 +1,29 @@
 package controllers
 
 import (
-	"context"
 	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *AppReconciler) reconcileServiceAccount(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
-	found := &corev1.ServiceAccount{}
-	desired := r.serviceAccount(app)
-	err := controllerutil.SetControllerReference(app, desired, r.Scheme)
-
-	err = r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, found)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			if err = r.Create(ctx, desired); err != nil {
-				return ctrl.Result{}, err
-			}
-			r.Log.Info("Created new ServiceAccount", "Name", app.Name, "Namespace", app.Namespace)
-			return ctrl.Result{Requeue: true}, nil
-		} else {
-			return ctrl.Result{}, err
-		}
-	}
+// serviceAccountGenerator produces the ServiceAccount the App's pods run as
+type serviceAccountGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
 
-	if !mapMatch(desired.Labels, found.Labels) || !mapMatch(desired.Annotations, found.Annotations) {
-		desired.DeepCopyInto(found)
-		r.Log.Info("Updating ServiceAccount", "Name", app.Name, "Namespace", app.Namespace)
-		if err := r.Update(ctx, found); err != nil {
-			return ctrl.Result{Requeue: true}, err
-		}
-	}
+func (g serviceAccountGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
 
-	return ctrl.Result{}, nil
+func (g serviceAccountGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	return g.r.serviceAccount(app), nil
 }
 
 func (r *AppReconciler) serviceAccount(app *kappv1alpha1.App) *corev1.ServiceAccount {
-	labels := make(map[string]string)
+	labels := appLabels(app)
 	annotations := make(map[string]string)
-	if app.Spec.Labels != nil {
-		for k, v := range app.Spec.Labels {
-			labels[k] = v
-		}
-	}
 
 	if app.Spec.Annotations != nil {
 		for k, v := range app.Spec.Annotations {
@@ -62,5 +38,6 @@ func (r *AppReconciler) serviceAccount(app *kappv1alpha1.App) *corev1.ServiceAcc
 			Labels:      labels,
 			Annotations: annotations,
 		},
+		ImagePullSecrets: app.Spec.ImagePullSecrets,
 	}
 }
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// statefulSetGenerator produces the StatefulSet running an App with the StatefulSet workload kind
+type statefulSetGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g statefulSetGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g statefulSetGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !statefulSetEnabled(app) {
+		return nil, nil
+	}
+
+	sts, err := g.r.statefulSet(app)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave the replica count to the HorizontalPodAutoscaler while it is active, see handOverReplicas
+	if autoscalerActive(app) {
+		sts.Spec.Replicas = nil
+	}
+	return sts, nil
+}
+
+// ready records the StatefulSet's status on the App and waits for the rollout to finish
+func (g statefulSetGenerator) ready(app *kappv1alpha1.App, obj client.Object) (bool, string) {
+	sts := obj.(*appsv1.StatefulSet)
+	clearWorkloadStatus(app)
+	app.Status.StatefulSet = sts.Status.DeepCopy()
+	if sts.Status.ObservedGeneration < sts.Generation || !statefulSetRolledOut(app.Status.StatefulSet, budgetInstances(app, minInstances(app))) {
+		return false, "Waiting for StatefulSet rollout to finish"
+	}
+	return true, ""
+}
+
+// statefulSetRolledOut reports whether every instance runs the latest revision and is ready
+func statefulSetRolledOut(status *appsv1.StatefulSetStatus, replicas int32) bool {
+	return status.UpdateRevision == status.CurrentRevision &&
+		status.UpdatedReplicas >= replicas &&
+		status.ReadyReplicas >= replicas
+}
+
+// headlessServiceGenerator produces the headless Service giving each StatefulSet instance a stable DNS name
+type headlessServiceGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g headlessServiceGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: headlessServiceName(app), Namespace: app.Namespace}}
+}
+
+func (g headlessServiceGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !statefulSetEnabled(app) || !receivesTraffic(app) {
+		return nil, nil
+	}
+
+	svc := g.r.service(app)
+	svc.Name = headlessServiceName(app)
+	svc.Spec.ClusterIP = corev1.ClusterIPNone
+	// Instances must be resolvable by their peers before they are ready, e.g. to form a cluster
+	svc.Spec.PublishNotReadyAddresses = true
+	return svc, nil
+}
+
+// statefulSetEnabled reports whether the App runs as a StatefulSet rather than a Deployment
+func statefulSetEnabled(app *kappv1alpha1.App) bool {
+	return app.Spec.WorkloadKind == kappv1alpha1.WorkloadStatefulSet
+}
+
+// workloadKind returns the kind of workload running the App
+func workloadKind(app *kappv1alpha1.App) string {
+	if app.Spec.WorkloadKind == "" {
+		return kappv1alpha1.WorkloadDeployment
+	}
+	return app.Spec.WorkloadKind
+}
+
+func headlessServiceName(app *kappv1alpha1.App) string {
+	return fmt.Sprintf("%s-headless", app.Name)
+}
+
+// statefulSet derives a StatefulSet from the App's deployment, turning its claim volumes
+// into per-instance claim templates
+func (r *AppReconciler) statefulSet(app *kappv1alpha1.App) (*appsv1.StatefulSet, error) {
+	dep, err := r.deployment(app)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimTemplates []corev1.PersistentVolumeClaim
+	for _, volume := range app.Spec.Volumes {
+		if volume.Claim == nil {
+			continue
+		}
+		// Claim templates can't be changed once the StatefulSet exists, so they only carry the
+		// App's selector labels rather than its labels, which may change
+		labels := make(map[string]string)
+		for k, v := range dep.Spec.Selector.MatchLabels {
+			labels[k] = v
+		}
+		claimTemplates = append(claimTemplates, corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   volume.Name,
+				Labels: labels,
+			},
+			Spec: claimSpec(volume.Claim),
+		})
+	}
+
+	return &appsv1.StatefulSet{
+		ObjectMeta: dep.ObjectMeta,
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            dep.Spec.Replicas,
+			Selector:            dep.Spec.Selector,
+			Template:            dep.Spec.Template,
+			ServiceName:         headlessServiceName(app),
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+			},
+			VolumeClaimTemplates: claimTemplates,
+		},
+	}, nil
+}
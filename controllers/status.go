@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// reconcileStep reconciles a single resource managed by an App, recording the outcome in condition.
+// Steps either produce their resource with a generator or run a reconcile function of their own.
+type reconcileStep struct {
+	condition string
+	generator generator
+	reconcile func(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error)
+}
+
+// runSteps reconciles each resource in order, stopping at the first failure.
+// The earliest RequeueAfter requested by any step is returned.
+func (r *AppReconciler) runSteps(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App, steps []reconcileStep) (ctrl.Result, error) {
+	result := ctrl.Result{}
+	for _, step := range steps {
+		res := ctrl.Result{}
+		ready, message := true, ""
+		var err error
+		if step.generator != nil {
+			ready, message, err = r.reconcileGenerated(ctx, app, step.generator)
+		} else {
+			res, err = step.reconcile(ctx, req, app)
+		}
+		if err != nil {
+			r.setCondition(app, step.condition, metav1.ConditionFalse, "ReconcileFailed", err.Error())
+			return res, err
+		}
+		if ready {
+			r.setCondition(app, step.condition, metav1.ConditionTrue, "Reconciled", "")
+		} else {
+			r.setCondition(app, step.condition, metav1.ConditionFalse, "NotReady", message)
+		}
+		if res.RequeueAfter > 0 && (result.RequeueAfter == 0 || res.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = res.RequeueAfter
+		}
+	}
+	return result, nil
+}
+
+func (r *AppReconciler) setCondition(app *kappv1alpha1.App, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: app.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setAppConditions derives the Ready, Progressing and Degraded conditions from the
+// outcome of the reconcile steps and the observed deployment status
+func (r *AppReconciler) setAppConditions(app *kappv1alpha1.App, reconcileErr error) {
+	app.Status.ObservedGeneration = app.Generation
+	if reconcileErr != nil {
+		app.Status.LastError = reconcileErr.Error()
+		r.setCondition(app, kappv1alpha1.ConditionDegraded, metav1.ConditionTrue, "ReconcileFailed", reconcileErr.Error())
+		r.setCondition(app, kappv1alpha1.ConditionReady, metav1.ConditionFalse, "ReconcileFailed", reconcileErr.Error())
+		return
+	}
+	app.Status.LastError = ""
+
+	if stuck, message := workloadStuck(app); stuck {
+		r.setCondition(app, kappv1alpha1.ConditionDegraded, metav1.ConditionTrue, "RolloutFailed", message)
+		r.setCondition(app, kappv1alpha1.ConditionProgressing, metav1.ConditionFalse, "RolloutFailed", message)
+		r.setCondition(app, kappv1alpha1.ConditionReady, metav1.ConditionFalse, "RolloutFailed", message)
+		return
+	}
+	if budget := meta.FindStatusCondition(app.Status.Conditions, kappv1alpha1.ConditionWithinBudget); budget != nil && budget.Status == metav1.ConditionFalse {
+		r.setCondition(app, kappv1alpha1.ConditionDegraded, metav1.ConditionTrue, budget.Reason, budget.Message)
+	} else {
+		r.setCondition(app, kappv1alpha1.ConditionDegraded, metav1.ConditionFalse, "Reconciled", "")
+	}
+
+	if !workloadAvailable(app) {
+		message := fmt.Sprintf("Waiting for %s rollout to finish", workloadKind(app))
+		if workloadKind(app) == kappv1alpha1.WorkloadJob {
+			message = "Waiting for Job to complete"
+		}
+		r.setCondition(app, kappv1alpha1.ConditionProgressing, metav1.ConditionTrue, "RolloutInProgress", message)
+		r.setCondition(app, kappv1alpha1.ConditionReady, metav1.ConditionFalse, "RolloutInProgress", message)
+		return
+	}
+	r.setCondition(app, kappv1alpha1.ConditionProgressing, metav1.ConditionFalse, "RolloutComplete", "")
+	r.setCondition(app, kappv1alpha1.ConditionReady, metav1.ConditionTrue, "Available", "")
+}
+
+// setInvalidSpec marks the App degraded by an effective spec that does not validate, most
+// likely because of the defaults it inherits from its Environment
+func (r *AppReconciler) setInvalidSpec(app *kappv1alpha1.App, err error) {
+	app.Status.ObservedGeneration = app.Generation
+	app.Status.LastError = err.Error()
+	r.setCondition(app, kappv1alpha1.ConditionDegraded, metav1.ConditionTrue, "InvalidSpec", err.Error())
+	r.setCondition(app, kappv1alpha1.ConditionReady, metav1.ConditionFalse, "InvalidSpec", err.Error())
+}
+
+// clearWorkloadStatus forgets the status of every workload kind, before the current one is recorded
+func clearWorkloadStatus(app *kappv1alpha1.App) {
+	app.Status.Deployment = nil
+	app.Status.StatefulSet = nil
+	app.Status.Job = nil
+	app.Status.CronJob = nil
+}
+
+// workloadAvailable reports whether the App's workload is available: every desired replica updated
+// and available for long-running workloads, the work completed for a Job, and scheduled for a CronJob
+func workloadAvailable(app *kappv1alpha1.App) bool {
+	switch workloadKind(app) {
+	case kappv1alpha1.WorkloadStatefulSet:
+		return app.Status.StatefulSet != nil && statefulSetRolledOut(app.Status.StatefulSet, budgetInstances(app, minInstances(app)))
+	case kappv1alpha1.WorkloadJob:
+		return app.Status.Job != nil && app.Status.Job.Succeeded > 0
+	case kappv1alpha1.WorkloadCronJob:
+		return app.Status.CronJob != nil
+	}
+
+	status := app.Status.Deployment
+	if status == nil {
+		return false
+	}
+	replicas := budgetInstances(app, minInstances(app))
+	return status.UpdatedReplicas >= replicas &&
+		status.AvailableReplicas >= replicas &&
+		status.Replicas == status.UpdatedReplicas
+}
+
+// workloadStuck reports whether the App's workload has given up, with the reason why
+func workloadStuck(app *kappv1alpha1.App) (bool, string) {
+	if workloadKind(app) == kappv1alpha1.WorkloadJob {
+		return jobFailed(app.Status.Job)
+	}
+	return deploymentStuck(app.Status.Deployment)
+}
+
+// deploymentStuck reports whether the deployment controller has given up on the current rollout
+func deploymentStuck(status *appsv1.DeploymentStatus) (bool, string) {
+	if status == nil {
+		return false, ""
+	}
+	for _, c := range status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return true, c.Message
+		}
+		if c.Type == appsv1.DeploymentReplicaFailure && c.Status == corev1.ConditionTrue {
+			return true, c.Message
+		}
+	}
+	return false, ""
+}
+
+func (r *AppReconciler) updateStatus(ctx context.Context, app *kappv1alpha1.App, original *kappv1alpha1.AppStatus) error {
+	if reflect.DeepEqual(original, &app.Status) {
+		return nil
+	}
+	r.Log.Info("Updating Status", "Name", app.Name, "Namespace", app.Namespace)
+	return r.Status().Update(ctx, app)
+}
@@ -1,6 +1,41 @@
 package controllers
 
-import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// appLabels returns the labels of the resources generated for an App: the App's own labels, the
+// labels of its effective spec, including those inherited from its Environment, and the app label
+func appLabels(app *kappv1alpha1.App) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range app.Labels {
+		labels[k] = v
+	}
+	for k, v := range app.Spec.Labels {
+		labels[k] = v
+	}
+	labels["app"] = app.Name
+	return labels
+}
+
+// appAnnotations returns the App's own annotations to copy onto its generated resources, leaving
+// out those that only mean something on the App, such as kappa's and kubectl's
+func appAnnotations(app *kappv1alpha1.App) map[string]string {
+	var annotations map[string]string
+	for k, v := range app.Annotations {
+		if strings.HasPrefix(k, "kappa.io/") || strings.HasPrefix(k, "kubectl.kubernetes.io/") {
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[k] = v
+	}
+	return annotations
+}
 
 func mapMatch(desired map[string]string, actual map[string]string) bool {
 	for k, v := range desired {
@@ -13,6 +48,28 @@ func mapMatch(desired map[string]string, actual map[string]string) bool {
 	return true
 }
 
-func (r *AppReconciler) logDifference(desired, actual interface{}, propertyName, name, namespace string, meta metav1.TypeMeta) {
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceListMatch validates both lists hold the same quantities, regardless of how they are formatted
+func resourceListMatch(desired corev1.ResourceList, actual corev1.ResourceList) bool {
+	if len(desired) != len(actual) {
+		return false
+	}
+	for k, v := range desired {
+		if a, ok := actual[k]; !ok || a.Cmp(v) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *EnvironmentReconciler) logDifference(desired, actual interface{}, propertyName, name, namespace string, meta metav1.TypeMeta) {
 	r.Log.Info("Updating mismatched values", "type", meta, "name", name, "namespace", namespace, "propertyName", propertyName, "desired", desired, "actual", actual)
 }
@@ -1,111 +1,197 @@
 package controllers
 
 import (
-	"context"
 	"fmt"
 	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
 	"istio.io/api/networking/v1alpha3"
 	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
-	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	"reflect"
-	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *AppReconciler) reconcileVirtualService(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
-	found := &istio.VirtualService{}
-	desired := r.virtualservice(app)
-	err := controllerutil.SetControllerReference(app, desired, r.Scheme)
-	if err != nil {
-		return ctrl.Result{}, err
+// virtualServiceGenerator produces the VirtualService routing traffic to the App
+type virtualServiceGenerator struct {
+	generatorDefaults
+	r *AppReconciler
+}
+
+func (g virtualServiceGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &istio.VirtualService{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+}
+
+func (g virtualServiceGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	if !receivesTraffic(app) {
+		return nil, nil
+	}
+	return g.r.virtualservice(app), nil
+}
+
+// isPublic reports whether the App should be exposed through the ingress gateway, defaulting to true
+func isPublic(app *kappv1alpha1.App) bool {
+	return app.Spec.Public == nil || *app.Spec.Public
+}
+
+// serviceHost returns the fully qualified in-cluster hostname of the App's Service
+func serviceHost(app *kappv1alpha1.App) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", app.Name, app.Namespace)
+}
+
+// publicHostname returns the hostname the App is exposed on, defaulting to the app name under the platform domain
+func (r *AppReconciler) publicHostname(app *kappv1alpha1.App) string {
+	if app.Spec.Hostname != "" {
+		return app.Spec.Hostname
+	}
+	if r.Domain == "" {
+		return app.Name
 	}
+	return fmt.Sprintf("%s.%s", app.Name, r.Domain)
+}
 
-	err = r.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, found)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			if err = r.Create(ctx, desired); err != nil {
-				return ctrl.Result{}, err
-			}
-			r.Log.Info("Created new VirtualService", "Name", app.Name, "Namespace", app.Namespace)
-			return ctrl.Result{Requeue: true}, nil
+// routeDestinations sends all traffic to the App's Service, to the live track of a blue/green App,
+// or splits it by track while a canary is active. A blue/green App keeps serving from the rolling
+// deployment until its active color is available, and from the active color until the rolling
+// deployment has taken over again when blue/green is removed.
+func routeDestinations(app *kappv1alpha1.App) []*v1alpha3.HTTPRouteDestination {
+	if blueGreenEnabled(app) || app.Status.BlueGreen != nil {
+		return []*v1alpha3.HTTPRouteDestination{
+			routeDestination(app, liveTrack(app), 0),
 		}
-	} else {
-		return ctrl.Result{}, err
 	}
 
-	if !reflect.DeepEqual(desired.Spec, found.Spec) {
-		desired.DeepCopyInto(found)
-		r.Log.Info("Updating VirtualService", "Name", app.Name, "Namespace", app.Namespace)
-		if err := r.Update(ctx, found); err != nil {
-			return ctrl.Result{Requeue: true}, err
+	if !canaryActive(app) {
+		return []*v1alpha3.HTTPRouteDestination{
+			routeDestination(app, "", 0),
 		}
 	}
-	return ctrl.Result{}, nil
+
+	return []*v1alpha3.HTTPRouteDestination{
+		routeDestination(app, trackStable, app.Status.Canary.StableWeight),
+		routeDestination(app, trackCanary, app.Status.Canary.CanaryWeight),
+	}
+}
+
+func routeDestination(app *kappv1alpha1.App, subset string, weight int32) *v1alpha3.HTTPRouteDestination {
+	return &v1alpha3.HTTPRouteDestination{
+		Destination: &v1alpha3.Destination{
+			Host:   serviceHost(app),
+			Subset: subset,
+			Port: &v1alpha3.PortSelector{
+				Number: 80,
+			},
+		},
+		Weight: weight,
+	}
+}
+
+// previewRoutes sends requests carrying the preview header, or addressed to the preview hostname,
+// to the preview color of a blue/green App
+func (r *AppReconciler) previewRoutes(app *kappv1alpha1.App) []*v1alpha3.HTTPRoute {
+	if !blueGreenPreviewing(app) {
+		return nil
+	}
+
+	matches := []*v1alpha3.HTTPMatchRequest{
+		{
+			Uri: prefixMatch(),
+			Headers: map[string]*v1alpha3.StringMatch{
+				previewHeader(app): {
+					MatchType: &v1alpha3.StringMatch_Exact{
+						Exact: "true",
+					},
+				},
+			},
+		},
+	}
+	if isPublic(app) {
+		matches = append(matches, &v1alpha3.HTTPMatchRequest{
+			Uri: prefixMatch(),
+			Authority: &v1alpha3.StringMatch{
+				MatchType: &v1alpha3.StringMatch_Exact{
+					Exact: r.previewHostname(app),
+				},
+			},
+		})
+	}
+
+	return []*v1alpha3.HTTPRoute{
+		httpRoute(matches, []*v1alpha3.HTTPRouteDestination{
+			routeDestination(app, app.Status.BlueGreen.PreviewColor, 0),
+		}),
+	}
+}
+
+func prefixMatch() *v1alpha3.StringMatch {
+	return &v1alpha3.StringMatch{
+		MatchType: &v1alpha3.StringMatch_Prefix{
+			Prefix: "/",
+		},
+	}
+}
+
+func httpRoute(matches []*v1alpha3.HTTPMatchRequest, destinations []*v1alpha3.HTTPRouteDestination) *v1alpha3.HTTPRoute {
+	return &v1alpha3.HTTPRoute{
+		Match: matches,
+		Route: destinations,
+		Headers: &v1alpha3.Headers{
+			Response: &v1alpha3.Headers_HeaderOperations{
+				Set: map[string]string{"Strict-Transport-Security": "max-age=31536000"},
+				Remove: []string{
+					"Server",
+					"server",
+				},
+			},
+		},
+		CorsPolicy: &v1alpha3.CorsPolicy{
+			AllowOrigins: []*v1alpha3.StringMatch{
+				{
+					MatchType: &v1alpha3.StringMatch_Regex{
+						Regex: ".*",
+					},
+				},
+			},
+			AllowMethods: []string{
+				"GET",
+				"POST",
+				"PUT",
+				"DELETE",
+				"PATCH",
+				"HEAD",
+				"OPTIONS",
+			},
+			AllowHeaders: []string{
+				"*",
+			},
+		},
+	}
 }
 
 func (r *AppReconciler) virtualservice(app *kappv1alpha1.App) *istio.VirtualService {
+	hosts := []string{serviceHost(app)}
+	var gateways []string
+	if isPublic(app) {
+		hosts = append([]string{r.publicHostname(app)}, hosts...)
+		if blueGreenEnabled(app) {
+			hosts = append(hosts, r.previewHostname(app))
+		}
+		// Without an ingress gateway the VirtualService keeps to the mesh, which is the default
+		if r.Gateway != "" {
+			gateways = []string{r.Gateway, "mesh"}
+		}
+	}
+
+	routes := r.previewRoutes(app)
+	routes = append(routes, httpRoute([]*v1alpha3.HTTPMatchRequest{{Uri: prefixMatch()}}, routeDestinations(app)))
+
 	return &istio.VirtualService{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      app.Name,
 			Namespace: app.Namespace,
-			Labels:    app.Labels,
+			Labels:    appLabels(app),
 		},
 		Spec: v1alpha3.VirtualService{
-			Http: []*v1alpha3.HTTPRoute{
-				{
-					Match: []*v1alpha3.HTTPMatchRequest{
-						{
-							Uri: &v1alpha3.StringMatch{
-								MatchType: &v1alpha3.StringMatch_Prefix{
-									Prefix: "/",
-								},
-							},
-						},
-					},
-					Route: []*v1alpha3.HTTPRouteDestination{
-						{
-							Destination: &v1alpha3.Destination{
-								Host: fmt.Sprintf("%s.%s.svc.cluster.local", app.Name, app.Namespace),
-								Port: &v1alpha3.PortSelector{
-									Number: 80,
-								},
-							},
-						},
-					},
-					Headers: &v1alpha3.Headers{
-						Response: &v1alpha3.Headers_HeaderOperations{
-							Set: map[string]string{"Strict-Transport-Security": "max-age=31536000"},
-							Remove: []string{
-								"Server",
-								"server",
-							},
-						},
-					},
-					CorsPolicy: &v1alpha3.CorsPolicy{
-						AllowOrigins: []*v1alpha3.StringMatch{
-							{
-								MatchType: &v1alpha3.StringMatch_Regex{
-									Regex: ".*",
-								},
-							},
-						},
-						AllowMethods: []string{
-							"GET",
-							"POST",
-							"PUT",
-							"DELETE",
-							"PATCH",
-							"HEAD",
-							"OPTIONS",
-						},
-						AllowHeaders: []string{
-							"*",
-						},
-					},
-				},
-			},
+			Hosts:    hosts,
+			Gateways: gateways,
+			Http:     routes,
 		},
 	}
 }
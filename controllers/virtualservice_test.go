@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestVirtualServiceHostsAndGateways(t *testing.T) {
+	r := &AppReconciler{Domain: "apps.example.com", Gateway: "istio-system/public"}
+	private := false
+
+	tests := []struct {
+		name     string
+		spec     kappv1alpha1.AppSpec
+		hosts    []string
+		gateways []string
+	}{
+		{
+			name:     "public by default",
+			hosts:    []string{"web.apps.example.com", "web.team.svc.cluster.local"},
+			gateways: []string{"istio-system/public", "mesh"},
+		},
+		{
+			name:     "custom hostname",
+			spec:     kappv1alpha1.AppSpec{Hostname: "www.example.com"},
+			hosts:    []string{"www.example.com", "web.team.svc.cluster.local"},
+			gateways: []string{"istio-system/public", "mesh"},
+		},
+		{
+			name:  "private",
+			spec:  kappv1alpha1.AppSpec{Public: &private},
+			hosts: []string{"web.team.svc.cluster.local"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}, Spec: tt.spec}
+			vs := r.virtualservice(app)
+			if !reflect.DeepEqual(vs.Spec.Hosts, tt.hosts) {
+				t.Errorf("hosts = %v, want %v", vs.Spec.Hosts, tt.hosts)
+			}
+			if !reflect.DeepEqual(vs.Spec.Gateways, tt.gateways) {
+				t.Errorf("gateways = %v, want %v", vs.Spec.Gateways, tt.gateways)
+			}
+		})
+	}
+}
+
+func TestVirtualServiceWithoutGateway(t *testing.T) {
+	r := &AppReconciler{Domain: "apps.example.com"}
+	app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}}
+
+	if gateways := r.virtualservice(app).Spec.Gateways; gateways != nil {
+		t.Errorf("gateways = %q, want the mesh default", gateways)
+	}
+}
+
+// An existing VirtualService must be reapplied when the App's hostname or visibility changes
+func TestVirtualServiceChangesAreApplied(t *testing.T) {
+	r := &AppReconciler{Domain: "apps.example.com", Gateway: "istio-system/public"}
+	g := virtualServiceGenerator{r: r}
+	private := false
+
+	tests := []struct {
+		name   string
+		before kappv1alpha1.AppSpec
+		after  kappv1alpha1.AppSpec
+		equal  bool
+	}{
+		{name: "unchanged", equal: true},
+		{name: "hostname changed", after: kappv1alpha1.AppSpec{Hostname: "www.example.com"}},
+		{name: "made private", after: kappv1alpha1.AppSpec{Public: &private}},
+		{name: "made public", before: kappv1alpha1.AppSpec{Public: &private}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &kappv1alpha1.App{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team"}, Spec: tt.before}
+			existing, err := g.desired(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := setAppliedHash(existing); err != nil {
+				t.Fatal(err)
+			}
+
+			app.Spec = tt.after
+			desired, err := g.desired(app)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := setAppliedHash(desired); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := g.equal(desired, existing); got != tt.equal {
+				t.Errorf("equal = %v, want %v", got, tt.equal)
+			}
+		})
+	}
+}
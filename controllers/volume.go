@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	kappv1alpha1 "github.com/jjoneson/kappa/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	tmpVolumeName = "tmp"
+	tmpMountPath  = "/tmp"
+)
+
+func (r *AppReconciler) reconcileVolumeClaims(ctx context.Context, req ctrl.Request, app *kappv1alpha1.App) (ctrl.Result, error) {
+	desiredNames := make(map[string]bool)
+	for _, volume := range app.Spec.Volumes {
+		// StatefulSets claim storage per instance through their claim templates instead
+		if volume.Claim == nil || statefulSetEnabled(app) {
+			continue
+		}
+		desiredNames[claimName(app, volume.Name)] = true
+		if _, _, err := r.reconcileGenerated(ctx, app, claimGenerator{r: r, volume: volume}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Remove claims of volumes that are no longer listed
+	existing := &corev1.PersistentVolumeClaimList{}
+	err := r.List(ctx, existing, client.InNamespace(app.Namespace), client.MatchingLabels{"app": app.Name})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range existing.Items {
+		pvc := &existing.Items[i]
+		if desiredNames[pvc.Name] || !metav1.IsControlledBy(pvc, app) {
+			continue
+		}
+		r.Log.Info("Deleting PersistentVolumeClaim", "Name", pvc.Name, "Namespace", pvc.Namespace)
+		if err := r.Delete(ctx, pvc); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// claimGenerator produces the PersistentVolumeClaim for one of the App's claim volumes
+type claimGenerator struct {
+	generatorDefaults
+	r      *AppReconciler
+	volume kappv1alpha1.Volume
+}
+
+func (g claimGenerator) object(app *kappv1alpha1.App) client.Object {
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: claimName(app, g.volume.Name), Namespace: app.Namespace}}
+}
+
+func (g claimGenerator) desired(app *kappv1alpha1.App) (client.Object, error) {
+	labels := appLabels(app)
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimName(app, g.volume.Name),
+			Namespace: app.Namespace,
+			Labels:    labels,
+		},
+		Spec: claimSpec(g.volume.Claim),
+	}, nil
+}
+
+// claimSpec requests the size, storage class and access modes of a claim volume, defaulting to ReadWriteOnce
+func claimSpec(claim *kappv1alpha1.ClaimVolume) corev1.PersistentVolumeClaimSpec {
+	accessModes := claim.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+
+	return corev1.PersistentVolumeClaimSpec{
+		AccessModes:      accessModes,
+		StorageClassName: claim.StorageClassName,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceStorage: claim.Size,
+			},
+		},
+	}
+}
+
+// mountsSingleNodeClaim reports whether the App's pods mount a volume only one node can attach.
+// The access modes of existing claims aren't known, so they count for single instance Apps;
+// Apps running several instances must be using them from several nodes already.
+func mountsSingleNodeClaim(app *kappv1alpha1.App) bool {
+	for _, volume := range app.Spec.Volumes {
+		if volume.Claim != nil && volume.Claim.SingleNode() {
+			return true
+		}
+		if volume.ExistingClaim != "" && !autoscalingEnabled(app) && minInstances(app) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func claimName(app *kappv1alpha1.App, volume string) string {
+	return fmt.Sprintf("%s-%s", app.Name, volume)
+}
+
+// readOnlyRootFilesystem reports whether the App's containers run with a read-only root filesystem
+func readOnlyRootFilesystem(app *kappv1alpha1.App) bool {
+	return app.Spec.ReadOnlyRootFilesystem != nil && *app.Spec.ReadOnlyRootFilesystem
+}
+
+// needsTmpVolume reports whether /tmp must be mounted to keep it writable under a read-only root filesystem
+func needsTmpVolume(app *kappv1alpha1.App) bool {
+	if !readOnlyRootFilesystem(app) {
+		return false
+	}
+	for _, volume := range app.Spec.Volumes {
+		if volume.MountPath == tmpMountPath {
+			return false
+		}
+	}
+	return true
+}
+
+// tmpVolumeMounts returns the /tmp mount every container gets under a read-only root filesystem
+func tmpVolumeMounts(app *kappv1alpha1.App) []corev1.VolumeMount {
+	if !needsTmpVolume(app) {
+		return nil
+	}
+	return []corev1.VolumeMount{
+		{
+			Name:      tmpVolumeName,
+			MountPath: tmpMountPath,
+		},
+	}
+}
+
+// podVolumes returns the pod volumes and the mounts of the application and init containers
+func podVolumes(app *kappv1alpha1.App) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes, mounts := configVolumes(app)
+
+	for _, volume := range app.Spec.Volumes {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: volume.MountPath,
+			ReadOnly:  volume.ReadOnly,
+		})
+
+		// StatefulSet claim templates provide the volume under the same name
+		if volume.Claim != nil && statefulSetEnabled(app) {
+			continue
+		}
+
+		source := corev1.VolumeSource{}
+		switch {
+		case volume.EmptyDir != nil:
+			source.EmptyDir = &corev1.EmptyDirVolumeSource{
+				Medium:    volume.EmptyDir.Medium,
+				SizeLimit: volume.EmptyDir.SizeLimit,
+			}
+		case volume.Claim != nil:
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: claimName(app, volume.Name),
+			}
+		default:
+			source.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: volume.ExistingClaim,
+			}
+		}
+
+		volumes = append(volumes, corev1.Volume{Name: volume.Name, VolumeSource: source})
+	}
+
+	if needsTmpVolume(app) {
+		volumes = append(volumes, corev1.Volume{
+			Name:         tmpVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		mounts = append(mounts, tmpVolumeMounts(app)...)
+	}
+
+	return volumes, mounts
+}
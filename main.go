@@ -19,13 +19,17 @@ package main
 import (
 	"flag"
 	istio "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	security "istio.io/client-go/pkg/apis/security/v1beta1"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -45,6 +49,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(istio.AddToScheme(scheme))
+	utilruntime.Must(security.AddToScheme(scheme))
 	utilruntime.Must(kappv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -53,8 +58,27 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var domain string
+	var gateway string
+	var gatewaySelector string
+	var gatewayServiceAccount string
+	var trustDomain string
+	var cleanupKinds string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&domain, "domain", "", "The platform domain public App hostnames default to a subdomain of.")
+	flag.StringVar(&gateway, "ingress-gateway", "istio-system/istio-ingressgateway",
+		"The Istio Gateway, as <namespace>/<name>, that public Apps are bound to. Public Apps are only routed within the mesh when empty.")
+	flag.StringVar(&gatewaySelector, "ingress-gateway-selector", "istio=ingressgateway",
+		"Labels, as key=value pairs separated by commas, of the ingress gateway pods serving the Istio Gateway.")
+	flag.StringVar(&gatewayServiceAccount, "ingress-gateway-service-account", "istio-system/istio-ingressgateway-service-account",
+		"The ServiceAccount, as <namespace>/<name>, of the ingress gateway pods serving the Istio Gateway.")
+	flag.StringVar(&trustDomain, "mesh-trust-domain", "cluster.local",
+		"The trust domain of the Istio mesh, which AuthorizationPolicies name workload identities under.")
+	flag.StringVar(&cleanupKinds, "cleanup-kinds", "",
+		"Comma separated kinds, as Kind.version.group, of objects labelled with an App's name and namespace "+
+			"that are deleted along with the App. The labels must be set by whatever creates the objects, and "+
+			"the manager needs an additional ClusterRole allowing list and delete on each kind.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -79,10 +103,36 @@ func main() {
 		os.Exit(1)
 	}
 
+	gatewayLabels, err := labels.ConvertSelectorToLabelsMap(gatewaySelector)
+	if err != nil {
+		setupLog.Error(err, "invalid ingress gateway selector", "selector", gatewaySelector)
+		os.Exit(1)
+	}
+
+	var cleanupHooks []controllers.CleanupHook
+	if cleanupKinds != "" {
+		var kinds []schema.GroupVersionKind
+		for _, arg := range strings.Split(cleanupKinds, ",") {
+			gvk, _ := schema.ParseKindArg(strings.TrimSpace(arg))
+			if gvk == nil {
+				setupLog.Error(nil, "invalid cleanup kind, expected Kind.version.group", "kind", arg)
+				os.Exit(1)
+			}
+			kinds = append(kinds, *gvk)
+		}
+		cleanupHooks = append(cleanupHooks, &controllers.LabelledObjectCleanup{Client: mgr.GetClient(), Kinds: kinds})
+	}
+
 	if err = (&controllers.AppReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("App"),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controllers").WithName("App"),
+		Scheme:                mgr.GetScheme(),
+		Domain:                domain,
+		Gateway:               gateway,
+		GatewaySelector:       gatewayLabels,
+		GatewayServiceAccount: gatewayServiceAccount,
+		TrustDomain:           trustDomain,
+		CleanupHooks:          cleanupHooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "App")
 		os.Exit(1)
@@ -95,6 +145,12 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Environment")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&kappv1alpha1.App{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "App")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {